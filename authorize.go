@@ -0,0 +1,196 @@
+package powermux
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Authorizer decides if a request may be served by a route that declares requirements.
+//
+// Authorize is called with the request, its method and every requirement that applies to the matched
+// route and method, in the order they were declared from the root down. A non-nil error denies the
+// request. Errors with a `StatusCode() int` method, or that wrap one, choose the response status,
+// otherwise http.StatusForbidden is used.
+type Authorizer interface {
+	Authorize(req *http.Request, method string, requirements []string) error
+}
+
+// The AuthorizerFunc type is an adapter to allow the use of ordinary functions as Authorizers.
+type AuthorizerFunc func(*http.Request, string, []string) error
+
+// Authorize calls f(req, method, requirements).
+func (f AuthorizerFunc) Authorize(req *http.Request, method string, requirements []string) error {
+	return f(req, method, requirements)
+}
+
+// statusCoder is implemented by errors that choose their response status
+type statusCoder interface {
+	StatusCode() int
+}
+
+type requirementForVerb struct {
+	// the requirement, empty for an explicitly public route
+	req  string
+	verb verbFlag
+}
+
+// Require declares requirements that requests to this route and every route below it must satisfy.
+// Requirements are enforced by the Authorizer set on the ServeMux.
+//
+// HEAD requests served by a GET handler must satisfy the requirements declared for GET as well as for HEAD.
+func (r *Route) Require(requirements ...string) *Route {
+	for _, req := range requirements {
+		r.requirements = append(r.requirements, &requirementForVerb{
+			req:  req,
+			verb: flagAny,
+		})
+	}
+	r.version.invalidate()
+	return r
+}
+
+// RequireFor declares a requirement for this route and every route below it,
+// but only for requests with the verb specified.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (r *Route) RequireFor(requirement string, verbs ...string) *Route {
+
+	// Equivalent to none
+	if len(verbs) == 0 {
+		return r
+	}
+
+	f := getVerbFlagForMethods(verbs)
+
+	r.requirements = append(r.requirements, &requirementForVerb{
+		req:  requirement,
+		verb: f,
+	})
+	r.version.invalidate()
+
+	return r
+}
+
+// Public declares that this route and every route below it are intentionally served without
+// requirements, so they are not reported by UnprotectedRoutes.
+// Requirements declared further down the tree still apply.
+func (r *Route) Public() *Route {
+	r.requirements = append(r.requirements, &requirementForVerb{
+		verb: flagAny,
+	})
+	r.version.invalidate()
+	return r
+}
+
+// headRequirements appends the requirements for HEAD or GET requests of this route and all above it,
+// from the root down, for HEAD requests served by the GET handler
+func (r *Route) headRequirements(reqs []string) []string {
+	if r.parent != nil {
+		reqs = r.parent.headRequirements(reqs)
+	}
+	for _, req := range r.requirements {
+		if req.req != "" && (req.verb.Matches(flagHead) || req.verb.Matches(flagGet)) {
+			reqs = append(reqs, req.req)
+		}
+	}
+	return reqs
+}
+
+// authorizedHandler checks the requirements collected for a request before running its handler
+type authorizedHandler struct {
+	requirements []string
+	authorizer   Authorizer
+	handler      http.Handler
+}
+
+// ServeHTTP runs the handler if the request is authorized, and responds with an error otherwise
+func (a *authorizedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := a.authorizer.Authorize(r, r.Method, a.requirements)
+	if err == nil {
+		a.handler.ServeHTTP(w, r)
+		return
+	}
+
+	status := statusOf(err, http.StatusForbidden)
+
+	if ex := lookupExecution(r); ex != nil && ex.renderer != nil {
+		ex.renderer.RenderProblem(w, r, newProblem(r, status))
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// Authorizer sets the Authorizer used to enforce the requirements declared on routes.
+// Requests to routes without requirements are not passed to the Authorizer.
+// Passing nil disables enforcement.
+func (s *ServeMux) Authorizer(authorizer Authorizer) {
+	s.authorizer = authorizer
+	s.baseRoute.version.invalidate()
+}
+
+// UnprotectedRoutes returns every method and route that has a handler, but no requirements
+// or Public declaration that applies to it. Each entry is the method followed by the route,
+// in the same format as String.
+func (s *ServeMux) UnprotectedRoutes() []string {
+	routes := make([]string, 0)
+	s.baseRoute.unprotectedRoutes("", nil, &routes)
+
+	hosts := make([]string, 0, len(s.hostRoutes))
+	for host := range s.hostRoutes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		s.hostRoutes[host].unprotectedRoutes(host, nil, &routes)
+	}
+
+	return routes
+}
+
+// unprotectedRoutes collects the unprotected routes of this node and all below it
+func (r *Route) unprotectedRoutes(host string, inherited []*requirementForVerb, routes *[]string) {
+
+	// our requirements apply along with everything above us
+	declared := make([]*requirementForVerb, 0, len(inherited)+len(r.requirements))
+	declared = append(declared, inherited...)
+	declared = append(declared, r.requirements...)
+
+	path := r.fullPath
+	if path == "" {
+		path = "/"
+	}
+
+	methods := make([]string, 0, len(r.handlers))
+	for method := range r.handlers {
+		if method != notFound {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		verb := flagAny
+		if method != methodAny {
+			verb = getVerbFlagForMethod(method)
+		}
+
+		// HEAD requests served by a GET handler are held to its requirements as well,
+		// so they're covered whenever the GET handler is
+		protected := false
+		for _, req := range declared {
+			if req.verb.Matches(verb) {
+				protected = true
+				break
+			}
+		}
+
+		if !protected {
+			*routes = append(*routes, method+" "+host+path)
+		}
+	}
+
+	for _, child := range r.getChildren() {
+		child.unprotectedRoutes(host, declared, routes)
+	}
+}
@@ -0,0 +1,148 @@
+package powermux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type unauthenticated struct{}
+
+func (unauthenticated) Error() string   { return "unauthenticated" }
+func (unauthenticated) StatusCode() int { return http.StatusUnauthorized }
+
+func TestServeMux_Authorizer(t *testing.T) {
+	s := NewServeMux()
+
+	var got []string
+	s.Authorizer(AuthorizerFunc(func(r *http.Request, method string, reqs []string) error {
+		got = append(got[0:0], reqs...)
+		if r.Header.Get("Authorization") == "" {
+			return unauthenticated{}
+		}
+		if r.Header.Get("Authorization") != strings.Join(reqs, ",") {
+			return errors.New("missing scope")
+		}
+		return nil
+	}))
+
+	s.Route("/admin").Require("admin:read").RequireFor("admin:write", http.MethodPost).Options(rightHandler)
+	s.Route("/admin/users").Get(rightHandler).Post(rightHandler)
+	s.Route("/open").Get(rightHandler)
+	s.Route("/private").Require("private").Get(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		auth   string
+		code   int
+		reqs   string
+	}{
+		{method: http.MethodGet, path: "/admin/users", auth: "admin:read", code: http.StatusOK, reqs: "admin:read"},
+		{method: http.MethodPost, path: "/admin/users", auth: "admin:read", code: http.StatusForbidden, reqs: "admin:read,admin:write"},
+		{method: http.MethodPost, path: "/admin/users", auth: "admin:read,admin:write", code: http.StatusOK, reqs: "admin:read,admin:write"},
+		{method: http.MethodGet, path: "/admin/users", code: http.StatusUnauthorized, reqs: "admin:read"},
+		{method: http.MethodGet, path: "/open", code: http.StatusOK},
+		{method: http.MethodGet, path: "/admin/missing", code: http.StatusNotFound},
+		// explicit OPTIONS handlers are protected, even when inherited
+		{method: http.MethodOptions, path: "/admin", code: http.StatusUnauthorized, reqs: "admin:read"},
+		{method: http.MethodOptions, path: "/admin/users", code: http.StatusUnauthorized, reqs: "admin:read"},
+		{method: http.MethodOptions, path: "/admin/users", auth: "admin:read", code: http.StatusOK, reqs: "admin:read"},
+		{method: http.MethodOptions, path: "/admin/missing", code: http.StatusUnauthorized, reqs: "admin:read"},
+		// generated responses aren't
+		{method: http.MethodOptions, path: "/private", code: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/private", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path+tt.auth, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Errorf("Wrong response code, expected=%d actual=%d", tt.code, rec.Code)
+			}
+			if strings.Join(got, ",") != tt.reqs {
+				t.Errorf("Wrong requirements, expected=%s actual=%v", tt.reqs, got)
+			}
+		})
+	}
+}
+
+func TestServeMux_UnprotectedRoutes(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").Get(wrongHandler)
+	s.Route("/admin").Require("admin")
+	s.Route("/admin/users").Get(rightHandler)
+	s.Route("/health").Public().Get(rightHandler)
+	s.Route("/posts").RequireFor("posts:write", http.MethodPost).Get(wrongHandler).Post(rightHandler)
+	s.RouteHost("example.com", "/").Any(wrongHandler)
+
+	unprotected := s.UnprotectedRoutes()
+	expected := []string{
+		"GET /",
+		"GET /posts",
+		"ANY example.com/",
+	}
+
+	if strings.Join(unprotected, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong unprotected routes:\n%s", strings.Join(unprotected, "\n"))
+	}
+}
+
+func TestServeMux_AuthorizerHead(t *testing.T) {
+	s := NewServeMux()
+
+	var got []string
+	s.Authorizer(AuthorizerFunc(func(r *http.Request, method string, reqs []string) error {
+		got = append(got[0:0], reqs...)
+		return errors.New("denied")
+	}))
+
+	s.Route("/reports").RequireFor("reports", http.MethodGet).Get(rightHandler)
+	s.Route("/audit").RequireFor("audit", http.MethodHead).Get(rightHandler)
+	s.Route("/status").RequireFor("status", http.MethodGet).Get(rightHandler).Head(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		reqs   string
+	}{
+		// HEAD is served by the GET handler, so it's held to the same requirements
+		{method: http.MethodGet, path: "/reports", code: http.StatusForbidden, reqs: "reports"},
+		{method: http.MethodHead, path: "/reports", code: http.StatusForbidden, reqs: "reports"},
+		{method: http.MethodGet, path: "/audit", code: http.StatusOK},
+		{method: http.MethodHead, path: "/audit", code: http.StatusForbidden, reqs: "audit"},
+		// unless it has a handler of its own
+		{method: http.MethodHead, path: "/status", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			got = nil
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.code {
+				t.Errorf("Wrong response code, expected=%d actual=%d", tt.code, rec.Code)
+			}
+			if strings.Join(got, ",") != tt.reqs {
+				t.Errorf("Wrong requirements, expected=%s actual=%v", tt.reqs, got)
+			}
+		})
+	}
+
+	unprotected := s.UnprotectedRoutes()
+	if strings.Join(unprotected, "\n") != "GET /audit\nHEAD /status" {
+		t.Errorf("Wrong unprotected routes:\n%s", strings.Join(unprotected, "\n"))
+	}
+}
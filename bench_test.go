@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"testing"
 )
 
@@ -13,6 +14,8 @@ const (
 	MaxDepth  = 100
 	FanDepth  = 4
 	FanSpread = 8
+
+	MiddlewareDepth = 10
 )
 
 type noopHandler struct{}
@@ -59,6 +62,7 @@ func BenchmarkNarrowAndDeep(b *testing.B) {
 	}
 	r.Handle(route, emptyHandle)
 	req := httptest.NewRequest(http.MethodGet, route, nil)
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -89,6 +93,7 @@ func BenchmarkFan(b *testing.B) {
 		req := httptest.NewRequest(http.MethodGet, route, nil)
 		requests = append(requests, req)
 	}
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -96,6 +101,85 @@ func BenchmarkFan(b *testing.B) {
 	}
 }
 
+// BenchmarkShallowAndWideTree routes the shallow and wide workload by walking the tree instead of
+// with the static index, so each request matches its path against the radix tree below the root
+func BenchmarkShallowAndWideTree(b *testing.B) {
+	staticPathsDisabled = true
+	defer func() { staticPathsDisabled = false }()
+	BenchmarkShallowAndWide(b)
+}
+
+// BenchmarkNarrowAndDeepTree routes the narrow and deep workload by walking the tree instead of
+// with the static index, so the whole path is matched as one chain of literals
+func BenchmarkNarrowAndDeepTree(b *testing.B) {
+	staticPathsDisabled = true
+	defer func() { staticPathsDisabled = false }()
+	BenchmarkNarrowAndDeep(b)
+}
+
+// BenchmarkFanTree routes the fan workload by walking the tree instead of with the static index
+func BenchmarkFanTree(b *testing.B) {
+	staticPathsDisabled = true
+	defer func() { staticPathsDisabled = false }()
+	BenchmarkFan(b)
+}
+
+// literalSiblings returns a route with MaxWidth literal children that share long prefixes,
+// and the patterns of the children
+func literalSiblings() (*Route, []string) {
+	r := newRoute()
+	patterns := make([]string, 0, MaxWidth)
+	for i := 0; i < MaxWidth; i++ {
+		pattern := fmt.Sprintf("resource-%04d", i)
+		r.Route(pattern)
+		patterns = append(patterns, pattern)
+	}
+	return r, patterns
+}
+
+// BenchmarkLiteralLookup finds a child in the radix tree of a route's literal children
+func BenchmarkLiteralLookup(b *testing.B) {
+	r, patterns := literalSiblings()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if r.literals.lookup(patterns[i%len(patterns)]) == nil {
+			b.Fatal("child not found")
+		}
+	}
+}
+
+// BenchmarkLiteralLookupSorted finds a child by binary searching the sorted children,
+// as routing did before the radix tree, for comparison with BenchmarkLiteralLookup
+func BenchmarkLiteralLookupSorted(b *testing.B) {
+	r, patterns := literalSiblings()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		pattern := patterns[i%len(patterns)]
+		j := sort.Search(len(r.children), func(j int) bool {
+			return r.children[j].pattern >= pattern
+		})
+		if j == len(r.children) || r.children[j].pattern != pattern {
+			b.Fatal("child not found")
+		}
+	}
+}
+
+func BenchmarkParams(b *testing.B) {
+	r := NewServeMux()
+	r.Route("/users/:id/files/:file").Get(emptyHandle)
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew/files/readme", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(nil, req)
+	}
+}
+
 func BenchmarkSingleRouteParallel(b *testing.B) {
 	r := NewServeMux()
 	r.Route("/").Any(emptyHandle)
@@ -162,3 +246,58 @@ func BenchmarkFanParallel(b *testing.B) {
 		}
 	})
 }
+
+type noopMiddleware struct{}
+
+func (m *noopMiddleware) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, n func(http.ResponseWriter, *http.Request)) {
+	n(rw, req)
+}
+
+var emptyMiddleware = &noopMiddleware{}
+
+// addMiddlewareRoute adds a route MiddlewareDepth deep with a middleware at every level
+func addMiddlewareRoute(r *ServeMux) string {
+	var route string
+	for i := 0; i < MiddlewareDepth; i++ {
+		route += "/" + hex.EncodeToString([]byte(fmt.Sprint(i)))
+		r.Route(route).Middleware(emptyMiddleware)
+	}
+	r.Route(route).Get(emptyHandle)
+	return route
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	r := NewServeMux()
+	req := httptest.NewRequest(http.MethodGet, addMiddlewareRoute(r), nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(nil, req)
+	}
+}
+
+func BenchmarkMiddlewareFrozen(b *testing.B) {
+	r := NewServeMux()
+	req := httptest.NewRequest(http.MethodGet, addMiddlewareRoute(r), nil)
+	r.Freeze()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(nil, req)
+	}
+}
+
+func BenchmarkMiddlewareConditional(b *testing.B) {
+	r := NewServeMux()
+	route := addMiddlewareRoute(r)
+	r.Route(route).MiddlewareIf(IfMatch(MatchRoute), emptyMiddleware)
+	req := httptest.NewRequest(http.MethodGet, route, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(nil, req)
+	}
+}
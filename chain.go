@@ -0,0 +1,180 @@
+package powermux
+
+import (
+	"math/bits"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// routesVersion counts the changes made to the routes and settings of a ServeMux that affect how requests are
+// routed and served. Every route of a ServeMux shares one, so changing the routes of one ServeMux doesn't affect
+// any other. Compiled chains and static paths from an older version are rebuilt the next time they're used.
+type routesVersion struct {
+	n uint64
+}
+
+// load returns the current version
+func (v *routesVersion) load() uint64 {
+	return atomic.LoadUint64(&v.n)
+}
+
+// invalidate marks every compiled chain and static path of the routes as out of date
+func (v *routesVersion) invalidate() {
+	atomic.AddUint64(&v.n, 1)
+}
+
+// compiledChain is the middleware and handler for a route and verb, nested ahead of time.
+// Routes with conditional middleware have a chain for each combination of conditions that held.
+type compiledChain struct {
+	version uint64
+	// the chain when no conditional middleware applies
+	handler http.Handler
+	// the chains for the conditional middleware that applies, by the conditions that held
+	variants map[uint64]http.Handler
+}
+
+// chainLink runs a middleware with the rest of the chain as its next function
+type chainLink struct {
+	mid  Middleware
+	next func(http.ResponseWriter, *http.Request)
+}
+
+// ServeHTTP runs the middleware
+func (l *chainLink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mid.ServeHTTPMiddleware(w, r, l.next)
+}
+
+// compileChain nests the middleware around the handler once, so serving a request through it
+// doesn't need to build anything.
+// Standard middleware is given the rest of the chain directly, and only called once.
+func compileChain(mids []Middleware, h http.Handler) http.Handler {
+	for i := len(mids) - 1; i >= 0; i-- {
+		if a, ok := mids[i].(adaptedMiddleware); ok {
+			h = a(h)
+		} else {
+			h = &chainLink{
+				mid:  mids[i],
+				next: h.ServeHTTP,
+			}
+		}
+	}
+	return h
+}
+
+// verbIndex is the position of a verb in a route's compiled chains
+func verbIndex(verb verbFlag) int {
+	return bits.TrailingZeros16(uint16(verb))
+}
+
+// compiledChain returns the chain compiled for the method and the conditions that held,
+// or nil if there isn't an up to date one
+func (r *Route) compiledChain(method string, held uint64) http.Handler {
+	c, ok := r.chains[verbIndex(getVerbFlagForRequest(method))].Load().(*compiledChain)
+	if !ok || c.version != r.version.load() {
+		return nil
+	}
+	if held == 0 {
+		return c.handler
+	}
+	return c.variants[held]
+}
+
+// compile builds and saves the chain for an execution that matched this route
+func (r *Route) compile(ex *routeExecution) http.Handler {
+	version := r.version.load()
+
+	// the pooled authorization handler can't be kept
+	handler := ex.handler
+	if handler == http.Handler(&ex.auth) {
+		auth := ex.auth
+		auth.requirements = make([]string, len(ex.requirements))
+		copy(auth.requirements, ex.requirements)
+		handler = &auth
+	}
+	handler = compileChain(ex.middleware, handler)
+
+	// keep the chains already compiled for other conditions, they're replaced rather than changed
+	// as other requests may be reading them
+	chains := &r.chains[verbIndex(getVerbFlagForRequest(ex.method))]
+	c := &compiledChain{version: version}
+	if old, ok := chains.Load().(*compiledChain); ok && old.version == version {
+		c.handler = old.handler
+		c.variants = make(map[uint64]http.Handler, len(old.variants)+1)
+		for held, h := range old.variants {
+			c.variants[held] = h
+		}
+	}
+	if ex.held == 0 {
+		c.handler = handler
+	} else {
+		if c.variants == nil {
+			c.variants = make(map[uint64]http.Handler, 1)
+		}
+		c.variants[ex.held] = handler
+	}
+	chains.Store(c)
+	return handler
+}
+
+// chain returns the handler that runs the execution's middleware and handler.
+// Requests that matched a route use the chain compiled for it and the conditional middleware that applies,
+// which is built on first use and reused until any route changes.
+func (s *ServeMux) chain(ex *routeExecution) http.Handler {
+	// requests that didn't match a route, or with too many conditions to tell the chains apart, are built each time
+	if ex.kind != MatchRoute || ex.conditional > 64 {
+		return http.HandlerFunc(getNextMiddleware(ex.middleware, ex.handler))
+	}
+	if h := ex.route.compiledChain(ex.method, ex.held); h != nil {
+		return h
+	}
+	return ex.route.compile(ex)
+}
+
+// Freeze compiles the middleware chains of every route and method registered so far, and routes every
+// fully literal path, instead of doing each the first time it's requested. Chains with conditional middleware
+// depend on the request, and are still compiled the first time each combination of conditions is requested.
+//
+// Routes can still be changed after Freeze, the chains affected are compiled again when next requested.
+func (s *ServeMux) Freeze() {
+	s.baseRoute.freeze(s, "")
+	for host, route := range s.hostRoutes {
+		route.freeze(s, host)
+	}
+}
+
+// freeze compiles the chains of this node and all below it
+func (r *Route) freeze(s *ServeMux, host string) {
+	path := r.fullPath
+	if path == "" {
+		path = "/"
+	}
+
+	for _, method := range r.allowed {
+		req := &http.Request{
+			Method: method,
+			URL:    &url.URL{Host: host, Path: path},
+			Header: make(http.Header),
+		}
+
+		ex := newExecution()
+		s.route(req, ex)
+
+		// params and wildcards may be matched by other routes, they're compiled when requested
+		if ex.kind != MatchRoute || ex.route != r {
+			continue
+		}
+
+		// the chains for conditional middleware depend on the request
+		if ex.hasConditions() {
+			continue
+		}
+
+		s.authorize(ex)
+		r.compile(ex)
+	}
+
+	for _, child := range r.getChildren() {
+		child.freeze(s, host)
+	}
+}
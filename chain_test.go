@@ -0,0 +1,238 @@
+package powermux
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChain_Invalidated(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/a/b").Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/a/b", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "right" {
+		t.Fatal("Wrong response", rec.Body.String())
+	}
+
+	// changes anywhere above the route must be picked up
+	s.Route("/a").Middleware(mid1)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "mid1right" {
+		t.Error("Middleware added after the chain was compiled not executed", rec.Body.String())
+	}
+
+	s.Route("/a/b").Get(wrongHandler, mid2)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "mid1mid2wrong" {
+		t.Error("Handler replaced after the chain was compiled not used", rec.Body.String())
+	}
+}
+
+func TestChain_InvalidatedPerServeMux(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/a").Get(rightHandler)
+	s.RouteHost("example.com", "/b").Get(rightHandler)
+	s.Freeze()
+
+	chain := s.Route("/a").compiledChain(http.MethodGet, 0)
+	index := s.static.get(s)
+	if chain == nil {
+		t.Fatal("Chain not compiled")
+	}
+
+	// other muxes don't share the routes
+	other := NewServeMux()
+	other.Route("/a").Middleware(mid1).Get(wrongHandler)
+	other.Authorizer(AuthorizerFunc(func(*http.Request, string, []string) error { return nil }))
+
+	if s.Route("/a").compiledChain(http.MethodGet, 0) != chain {
+		t.Error("Chain invalidated by another ServeMux")
+	}
+	if s.static.get(s) != index {
+		t.Error("Static paths invalidated by another ServeMux")
+	}
+
+	// host routes share the version of their ServeMux
+	s.RouteHost("example.com", "/b").Middleware(mid1)
+	if s.Route("/a").compiledChain(http.MethodGet, 0) != nil {
+		t.Error("Chain not invalidated by a change to host routes")
+	}
+}
+
+func TestChain_Freeze(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(mid1)
+	s.Route("/users/:id").Get(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(PathParam(r, "id")))
+	}))
+	s.Route("/files/*").Any(rightHandler, mid2)
+	s.RouteHost("example.com", "/").Get(wrongHandler)
+	s.Freeze()
+
+	tests := []struct {
+		host, path, body string
+	}{
+		{"", "/users/andrew", "mid1andrew"},
+		{"", "/users/:id", "mid1:id"},
+		{"", "/files/a/b", "mid1mid2right"},
+		{"example.com", "/", "wrong"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.URL.Host = test.host
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Body.String() != test.body {
+			t.Error("Wrong response for", test.host+test.path, rec.Body.String())
+		}
+	}
+}
+
+func TestChain_Conditional(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").MiddlewareIf(IfHeader("X-Mid", "1"), mid1)
+	s.Route("/a").Get(rightHandler)
+	s.Freeze()
+
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "right" {
+		t.Error("Conditional middleware executed", rec.Body.String())
+	}
+
+	req.Header.Set("X-Mid", "1")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "mid1right" {
+		t.Error("Conditional middleware not executed", rec.Body.String())
+	}
+
+	// a flat chain is compiled for each outcome of the condition
+	without, with := s.Route("/a").compiledChain(http.MethodGet, 0), s.Route("/a").compiledChain(http.MethodGet, 1)
+	if without == nil || with == nil || without == with {
+		t.Error("Conditional chains not compiled")
+	}
+	if _, ok := with.(*chainLink); !ok {
+		t.Error("Conditional middleware not in the chain")
+	}
+
+	// conditional standard middleware
+	s.Route("/b").MiddlewareIf(IfHeader("X-Mid", "1"), Adapt(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "use")
+			next.ServeHTTP(w, r)
+		})
+	})).Get(rightHandler)
+	for header, body := range map[string]string{"": "right", "1": "mid1useright"} {
+		req := httptest.NewRequest(http.MethodGet, "/b", nil)
+		req.Header.Set("X-Mid", header)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Body.String() != body {
+			t.Error("Wrong conditional standard middleware", header, rec.Body.String())
+		}
+	}
+}
+
+func TestChain_Authorizer(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/admin").Require("admin").Get(rightHandler)
+	s.Route("/users").Require("user").Get(rightHandler)
+
+	var got []string
+	s.Authorizer(AuthorizerFunc(func(req *http.Request, method string, requirements []string) error {
+		got = requirements
+		if requirements[0] != "user" {
+			return errors.New("denied")
+		}
+		return nil
+	}))
+	s.Freeze()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if rec.Code != http.StatusForbidden || len(got) != 1 || got[0] != "admin" {
+		t.Error("Wrong requirements enforced", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Code != http.StatusOK || len(got) != 1 || got[0] != "user" {
+		t.Error("Wrong requirements enforced", rec.Code, got)
+	}
+
+	// removing the authorizer must apply to compiled chains
+	s.Authorizer(nil)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if rec.Code != http.StatusOK {
+		t.Error("Authorizer still enforced", rec.Code)
+	}
+}
+
+func TestChain_AdaptedOnce(t *testing.T) {
+	s := NewServeMux()
+
+	wrapped := 0
+	s.Route("/").Use(func(next http.Handler) http.Handler {
+		wrapped++
+		return next
+	})
+	s.Route("/a").Get(rightHandler)
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+		if rec.Body.String() != "right" {
+			t.Error("Wrong response", rec.Body.String())
+		}
+	}
+
+	if wrapped != 1 {
+		t.Error("Standard middleware called for every request", wrapped)
+	}
+}
+
+func TestChain_Allocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Allocations aren't reliable with the race detector")
+	}
+
+	bare := NewServeMux()
+	bare.Route("/a/b/c").Get(emptyHandle)
+
+	mids := NewServeMux()
+	mids.Route("/").Middleware(emptyMiddleware)
+	mids.Route("/a").Middleware(emptyMiddleware).Use(func(next http.Handler) http.Handler {
+		return next
+	})
+	mids.Route("/a/b/c").Get(emptyHandle, emptyMiddleware)
+	mids.Route("/a/b").
+		MiddlewareIf(IfMatch(MatchRoute), emptyMiddleware).
+		MiddlewareIf(IfHeader("X-Skip", ""), emptyMiddleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/a/b/c", nil)
+
+	want := testing.AllocsPerRun(100, func() {
+		bare.ServeHTTP(nil, req)
+	})
+	got := testing.AllocsPerRun(100, func() {
+		mids.ServeHTTP(nil, req)
+	})
+
+	if got != want {
+		t.Error("Middleware chain allocates", got-want)
+	}
+}
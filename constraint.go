@@ -0,0 +1,79 @@
+package powermux
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ParamConstraint reports if a value is acceptable for a path parameter
+type ParamConstraint func(value string) bool
+
+// ParamParser converts the value of a path parameter, or returns an error if it can't be parsed
+type ParamParser func(value string) (interface{}, error)
+
+// Constrain rejects requests whose value for this path parameter doesn't satisfy the constraint.
+// They're sent to the bad request handler with a routing error of kind ErrConstraint, other routes that
+// could have matched the path aren't tried.
+//
+// Panics if the route isn't a path parameter.
+func (r *Route) Constrain(constraint ParamConstraint) *Route {
+	r.mustBeParam("Constrain")
+	r.constraint = constraint
+	r.version.invalidate()
+	return r
+}
+
+// Parse converts the value of this path parameter while the request is routed, after any constraint is checked.
+// The result is available to middleware and handlers with ParsedParam. Requests whose value can't be parsed are
+// sent to the bad request handler with a routing error of kind ErrParamParse.
+//
+// Panics if the route isn't a path parameter.
+func (r *Route) Parse(parser ParamParser) *Route {
+	r.mustBeParam("Parse")
+	r.parser = parser
+	r.version.invalidate()
+	return r
+}
+
+// ParsedParam returns the value of a path parameter converted by the parser of its route.
+// It returns nil if the parameter isn't set or its route doesn't parse it.
+func ParsedParam(req *http.Request, name string) interface{} {
+	ex := getRequestExecution(req)
+	return ex.parsedParam(name)
+}
+
+// mustBeParam panics if the route isn't a path parameter
+func (r *Route) mustBeParam(method string) {
+	if !r.isParam {
+		panic(fmt.Sprintf("powermux: can't %s %q, it isn't a path parameter", method, r.fullPath))
+	}
+}
+
+// checkParam applies the constraint and parser of this path parameter to the value just saved for it.
+// It returns false and sets the routing error if the value is rejected.
+func (r *Route) checkParam(ex *routeExecution, segment, value string) bool {
+	if r.constraint != nil && !r.constraint(value) {
+		ex.err = &RoutingError{
+			Kind:    ErrConstraint,
+			Segment: segment,
+			Param:   r.paramName,
+		}
+		return false
+	}
+
+	if r.parser != nil {
+		parsed, err := r.parser(value)
+		if err != nil {
+			ex.err = &RoutingError{
+				Kind:    ErrParamParse,
+				Segment: segment,
+				Param:   r.paramName,
+				Err:     err,
+			}
+			return false
+		}
+		ex.params[len(ex.params)-1].parsed = parsed
+	}
+
+	return true
+}
@@ -0,0 +1,145 @@
+package powermux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS is a Cross-Origin Resource Sharing policy that can be attached to a Route.
+//
+// Like NotFound handlers, a policy applies to the route it is attached to and every route below it,
+// unless another policy is attached further down the tree.
+type CORS struct {
+	// AllowOrigins is the list of origins allowed to make cross-origin requests.
+	// An entry of "*" allows any origin, and can't be used with AllowCredentials.
+	AllowOrigins []string
+	// AllowOriginFunc is consulted for any origin not listed in AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowHeaders is the list of request headers allowed in preflight requests.
+	// An entry of "*" allows any headers the client asks for.
+	AllowHeaders []string
+	// ExposeHeaders is the list of response headers the client is allowed to read.
+	ExposeHeaders []string
+	// AllowCredentials permits cookies and authorization headers on cross-origin requests.
+	// Credentialed requests are only allowed from the origins listed, or accepted by AllowOriginFunc.
+	AllowCredentials bool
+	// MaxAge is how long the results of a preflight request may be cached.
+	// Zero omits the header.
+	MaxAge time.Duration
+}
+
+// anyOrigin reports if the policy answers every origin with the same "*" response
+func (c *CORS) anyOrigin() bool {
+	return !c.AllowCredentials && c.AllowOriginFunc == nil && containsString(c.AllowOrigins, "*")
+}
+
+// allowsOrigin reports if the origin may make cross-origin requests.
+// A "*" entry never allows credentialed requests.
+func (c *CORS) allowsOrigin(origin string) bool {
+	for _, o := range c.AllowOrigins {
+		if (o == "*" && !c.AllowCredentials) || o == origin {
+			return true
+		}
+	}
+	if c.AllowOriginFunc != nil {
+		return c.AllowOriginFunc(origin)
+	}
+	return false
+}
+
+// setOrigin writes the headers common to preflight and actual requests
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin() {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// serve applies the policy to the request. Preflight requests are answered directly, in which case
+// serve returns true and no further handling should take place. All other requests have the relevant
+// headers added to the response and should continue on to their handler.
+//
+// allowed is the list of methods supported by the route, and preflights are only answered if there is one.
+// Preflights that have a registered handler are treated like any other request, and left to the handler.
+func (c *CORS) serve(w http.ResponseWriter, r *http.Request, allowed []string, registered bool) bool {
+	h := w.Header()
+	origin := r.Header.Get("Origin")
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+
+	// not a preflight, just decorate the response
+	if r.Method != http.MethodOptions || reqMethod == "" || origin == "" || len(allowed) == 0 || registered {
+		if !c.anyOrigin() {
+			h.Add("Vary", "Origin")
+		}
+		if origin != "" && c.allowsOrigin(origin) {
+			c.setOrigin(h, origin)
+			if len(c.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+			}
+		}
+		return false
+	}
+
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	// a preflight without any CORS headers is a refusal
+	if !c.allowsOrigin(origin) || !containsString(allowed, reqMethod) {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(allowed, ", "))
+
+	if containsString(c.AllowHeaders, "*") {
+		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+	} else if len(c.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+	}
+
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS attaches a Cross-Origin Resource Sharing policy to this route and every route below it.
+//
+// Preflight requests to routes with at least one handler are answered automatically, with
+// "Access-Control-Allow-Methods" listing the methods supported by the requested route.
+// Preflights are answered once the request is routed, before authorization and any middleware runs,
+// so authentication middleware does not need to exclude OPTIONS requests. A preflight served by a
+// registered OPTIONS handler, on the route or inherited from above it, is left to that handler and runs
+// through authorization and middleware like any other request.
+// All other requests have the appropriate CORS and "Vary" headers set before middleware and handlers are run.
+//
+// Panics if the policy allows credentials from any origin with "*", list the origins or use AllowOriginFunc.
+func (r *Route) CORS(policy *CORS) *Route {
+	if policy != nil && policy.AllowCredentials && containsString(policy.AllowOrigins, "*") {
+		panic("powermux: CORS can't allow credentials from any origin, list the origins or use AllowOriginFunc")
+	}
+	r.cors = policy
+	r.version.invalidate()
+	return r
+}
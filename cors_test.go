@@ -0,0 +1,190 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/api").
+		CORS(&CORS{
+			AllowOrigins: []string{"https://example.com"},
+			AllowHeaders: []string{"Content-Type"},
+			MaxAge:       time.Hour,
+		}).
+		Middleware(wrongHandler)
+	s.Route("/api/users").Get(wrongHandler).Post(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if rec.Body.Len() != 0 {
+		t.Error("Middleware should not run for preflights")
+	}
+
+	expect := map[string]string{
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": "GET, HEAD, OPTIONS, POST",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Access-Control-Max-Age":       "3600",
+	}
+	for header, value := range expect {
+		if rec.Header().Get(header) != value {
+			t.Errorf("Wrong %s header: %s", header, rec.Header().Get(header))
+		}
+	}
+
+	if len(rec.Header()["Vary"]) != 3 {
+		t.Error("Wrong Vary headers", rec.Header()["Vary"])
+	}
+}
+
+func TestCORS_PreflightRefused(t *testing.T) {
+	s := NewServeMux()
+
+	s.CORS(&CORS{AllowOrigins: []string{"https://example.com"}})
+	s.Route("/api").Get(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Origin should not have been allowed")
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	rec = httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Method should not have been allowed")
+	}
+}
+
+func TestCORS_Simple(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/api").CORS(&CORS{
+		AllowOriginFunc:  func(origin string) bool { return origin == "https://example.com" },
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+	})
+	s.Route("/api/users").Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "right" {
+		t.Error("Handler not run")
+	}
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Error("Wrong allowed origin", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Credentials not allowed")
+	}
+
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		t.Error("Wrong exposed headers", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+
+	if rec.Header().Get("Vary") != "Origin" {
+		t.Error("Wrong Vary header", rec.Header().Get("Vary"))
+	}
+}
+
+func TestCORS_SimpleAnyOrigin(t *testing.T) {
+	s := NewServeMux()
+
+	s.CORS(&CORS{AllowOrigins: []string{"*"}})
+	s.Route("/").Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("Wrong allowed origin", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	if rec.Header().Get("Vary") != "" {
+		t.Error("Response does not vary by origin")
+	}
+}
+
+func TestCORS_CredentialsAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Credentials allowed from any origin")
+		}
+	}()
+
+	s := NewServeMux()
+	s.CORS(&CORS{
+		AllowOrigins:     []string{"*"},
+		AllowCredentials: true,
+	})
+}
+
+func TestCORS_PreflightRegistered(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/api").
+		CORS(&CORS{AllowOrigins: []string{"https://example.com"}}).
+		Options(rightHandler)
+	s.Route("/api/users").Get(wrongHandler).Middleware(mid1)
+
+	for _, path := range []string{"/api", "/api/users"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rec := httptest.NewRecorder()
+
+		s.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Error("Preflight not left to the handler", path, rec.Code)
+		}
+		if rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Error("Preflight answered for", path)
+		}
+		if rec.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+			t.Error("Wrong allowed origin", rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "mid1right" {
+		t.Error("Middleware not run for the registered handler", rec.Body.String())
+	}
+}
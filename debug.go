@@ -0,0 +1,321 @@
+package powermux
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DebugHandler returns a handler that lists the routes of the ServeMux and explains how requests are matched.
+// It can be mounted at any path, and should only be served where it can't be reached publicly, such as an
+// admin port.
+//
+// The listing is served as HTML, or as JSON if the format query parameter is "json" or the request only
+// accepts JSON. Requests with a path query parameter also explain how a request with that path, and the
+// method and host query parameters, would be routed.
+func DebugHandler(s *ServeMux) http.Handler {
+	return &debugHandler{mux: s}
+}
+
+// debugHandler serves the listing and explanations for a ServeMux
+type debugHandler struct {
+	mux *ServeMux
+}
+
+// debugPage is everything the debug handler shows
+type debugPage struct {
+	Routes  []routeJSON  `json:"routes"`
+	Explain *explanation `json:"explain,omitempty"`
+	// the explained request, to fill the form with
+	Method  string   `json:"-"`
+	Host    string   `json:"-"`
+	Path    string   `json:"-"`
+	Methods []string `json:"-"`
+}
+
+// ServeHTTP serves the listing, and explains the request given by the query parameters if there is one
+func (d *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	routes, err := d.mux.routeTable()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := &debugPage{
+		Routes:  routes,
+		Method:  query.Get("method"),
+		Host:    query.Get("host"),
+		Path:    query.Get("path"),
+		Methods: verbMethods,
+	}
+	if page.Method == "" {
+		page.Method = http.MethodGet
+	}
+
+	if query.Has("path") {
+		req, err := explainRequest(page.Method, page.Host, page.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page.Explain = explain(d.mux.Explain(req))
+		page.Explain.Host = page.Host
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	debugTemplate.Execute(w, page)
+}
+
+// explainRequest builds the request to explain
+func explainRequest(method, host, path string) (*http.Request, error) {
+	valid := false
+	for _, m := range verbMethods {
+		valid = valid || m == method
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// parsed as a request would be, so paths starting with a double slash aren't taken as a host
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, err
+	}
+	u.Host = host
+
+	return &http.Request{
+		Method: method,
+		URL:    u,
+		Host:   host,
+		Header: make(http.Header),
+	}, nil
+}
+
+// explanation describes how a request was routed
+type explanation struct {
+	Method string `json:"method"`
+	Host   string `json:"host,omitempty"`
+	Path   string `json:"path"`
+	// the host whose routes were used, empty for the default routes
+	HostTree string           `json:"hostTree,omitempty"`
+	Nodes    []explainedNode  `json:"nodes"`
+	Params   []explainedParam `json:"params,omitempty"`
+	Kind     string           `json:"kind"`
+	Pattern  string           `json:"pattern,omitempty"`
+	Choice   string           `json:"choice"`
+	Handler  string           `json:"handler,omitempty"`
+	Chain    []string         `json:"middleware,omitempty"`
+	NotFound string           `json:"notFound,omitempty"`
+	Options  string           `json:"options,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// explainedNode is a route visited while routing a request
+type explainedNode struct {
+	Pattern string `json:"pattern"`
+	Segment string `json:"segment"`
+	Kind    string `json:"kind"`
+	// the middleware collected at the route
+	Collected []string `json:"collected,omitempty"`
+	// the middleware not collected because it's for other methods
+	Filtered []string `json:"filtered,omitempty"`
+	// the inherited middleware the route excluded
+	Excluded []string `json:"excluded,omitempty"`
+	// the children tried for the next segment, and why routing stopped here
+	Tried []string `json:"tried,omitempty"`
+	Stop  string   `json:"stop,omitempty"`
+}
+
+// explainedParam is a path parameter captured while routing a request
+type explainedParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// explain describes the trace for the debug handler
+func explain(trace MatchTrace) *explanation {
+	e := &explanation{
+		Method:   trace.Method,
+		Path:     trace.Path,
+		HostTree: trace.Match.Host,
+		Kind:     trace.Match.Kind.String(),
+		Pattern:  trace.Match.Pattern,
+		Choice:   trace.Choice,
+		Handler:  describe(trace.Handler),
+		NotFound: describeInherited(trace.NotFound),
+		Options:  describeInherited(trace.Options),
+	}
+	if trace.Err != nil {
+		e.Error = trace.Err.Error()
+	}
+
+	verb := getVerbFlagForRequest(trace.Method)
+	for _, step := range trace.Steps {
+		node := explainedNode{
+			Pattern: patternOf(step.Route),
+			Segment: step.Segment,
+			Kind:    nodeKind(step.Route),
+			Stop:    step.Stop,
+		}
+		for _, m := range step.Excluded {
+			node.Excluded = append(node.Excluded, describe(m))
+		}
+		if trace.endpoint != nil {
+			node.addMiddleware(groupMiddlewareAt(trace.endpoint.groupMiddleware[trace.endpointKey], step.Route), verb, "group")
+		}
+		node.addMiddleware(step.Route.middleware, verb, "")
+
+		// the middleware that only runs for requests ending here, or bound to the chosen handler
+		if step.Route == trace.Match.Route {
+			node.addMiddleware(step.Route.localMiddleware, verb, "local")
+		}
+		if step.Route == trace.endpoint {
+			node.addMiddleware(step.Route.endpointMiddleware[trace.endpointKey], verb, trace.endpointKey+" handler")
+		}
+		for _, attempt := range step.Attempts {
+			node.Tried = append(node.Tried, attempt.Kind.String()+": "+attempt.Reason)
+		}
+		e.Nodes = append(e.Nodes, node)
+	}
+
+	for _, p := range trace.Params {
+		e.Params = append(e.Params, explainedParam{
+			Name:  p.Name,
+			Value: p.Value,
+		})
+	}
+	for _, m := range trace.Middleware {
+		e.Chain = append(e.Chain, describe(m))
+	}
+	return e
+}
+
+// groupMiddlewareAt returns the middleware of the groups that's executed at the route
+func groupMiddlewareAt(groups []*groupMiddleware, r *Route) []*middlewareForVerb {
+	var mids []*middlewareForVerb
+	for _, g := range groups {
+		if g.anchor == r {
+			mids = append(mids, g.mid)
+		}
+	}
+	return mids
+}
+
+// describeInherited names the handler along with the route it was set on
+func describeInherited(h InheritedHandler) string {
+	if h.Handler == nil {
+		return ""
+	}
+	return describe(h.Handler) + " from " + patternOf(h.From)
+}
+
+// addMiddleware sorts the middleware into those collected for the verb and those filtered out
+func (n *explainedNode) addMiddleware(mids []*middlewareForVerb, verb verbFlag, scope string) {
+	for _, info := range middlewareInfo(mids, false) {
+		name := describeMiddleware(info)
+		if scope != "" {
+			name += " [" + scope + "]"
+		}
+		if info.Methods == nil || getVerbFlagForMethods(info.Methods).Matches(verb) {
+			n.Collected = append(n.Collected, name)
+		} else {
+			n.Filtered = append(n.Filtered, name)
+		}
+	}
+}
+
+// nodeKind describes how the route matches its path segment
+func nodeKind(r *Route) string {
+	switch {
+	case r.parent == nil:
+		return "root"
+	case r.isParam:
+		return "param"
+	case r.isWildcard:
+		return "wildcard"
+	default:
+		return "literal"
+	}
+}
+
+// wantsJSON reports if the debug handler should respond with JSON
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Routes</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code { font-size: 0.9em; }
+.filtered, .excluded { color: #999; text-decoration: line-through; }
+</style>
+</head>
+<body>
+<h1>Explain a request</h1>
+<form method="get">
+<select name="method">{{range .Methods}}<option{{if eq . $.Method}} selected{{end}}>{{.}}</option>{{end}}</select>
+<input name="host" placeholder="host" value="{{.Host}}">
+<input name="path" placeholder="/path" value="{{.Path}}" size="40">
+<button type="submit">Explain</button>
+</form>
+{{with .Explain}}
+<h2>{{.Method}} {{.Host}}{{.Path}}</h2>
+<p>Routes: {{if .HostTree}}<code>{{.HostTree}}</code>{{else}}default{{end}}</p>
+<table>
+<tr><th>Route</th><th>Segment</th><th>Kind</th><th>Middleware</th><th>Next</th></tr>
+{{range .Nodes}}<tr>
+<td><code>{{.Pattern}}</code></td><td><code>{{.Segment}}</code></td><td>{{.Kind}}</td>
+<td>{{range .Collected}}<div><code>{{.}}</code></div>{{end}}{{range .Filtered}}<div class="filtered" title="filtered by method"><code>{{.}}</code></div>{{end}}{{range .Excluded}}<div class="excluded" title="excluded"><code>{{.}}</code></div>{{end}}</td>
+<td>{{range .Tried}}<div>{{.}}</div>{{end}}{{if .Stop}}<div><strong>{{.Stop}}</strong></div>{{end}}</td>
+</tr>{{end}}
+</table>
+{{if .Params}}<p>Params: {{range .Params}}<code>{{.Name}}={{.Value}}</code> {{end}}</p>{{end}}
+<p>Result: {{.Kind}}{{if .Pattern}} <code>{{.Pattern}}</code>{{end}}, {{.Choice}}{{if .Handler}} <code>{{.Handler}}</code>{{end}}</p>
+{{if .NotFound}}<p>NotFound handler: <code>{{.NotFound}}</code></p>{{end}}
+{{if .Options}}<p>OPTIONS handler: <code>{{.Options}}</code></p>{{end}}
+{{if .Error}}<p>Error: {{.Error}}</p>{{end}}
+{{if .Chain}}<p>Middleware run: {{range .Chain}}<code>{{.}}</code> {{end}}</p>{{end}}
+{{end}}
+<h1>Routes</h1>
+<table>
+<tr><th>Host</th><th>Pattern</th><th>Handlers</th><th>Middleware</th></tr>
+{{range .Routes}}<tr>
+<td>{{.Host}}</td>
+<td><code>{{.Pattern}}</code></td>
+<td>{{range .Methods}}<div>{{.Method}} <code>{{.Handler}}</code>{{if .Request}} <code>{{.Request}} &rarr; {{.Response}}</code>{{end}}</div>{{end}}</td>
+<td>{{range .Middleware}}<div><code>{{.Middleware}}</code>{{if .Methods}} {{.Methods}}{{end}}{{if .Local}} local{{end}}{{if .Conditional}} conditional{{end}}</div>{{end}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
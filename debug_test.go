@@ -0,0 +1,168 @@
+package powermux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func debugMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/").Options(rightHandler)
+	s.Route("/users").
+		Middleware(mid1).
+		MiddlewareFor(mid2, http.MethodPost).
+		Without("audit")
+	s.Route("/users/:id").
+		Get(rightHandler).
+		LocalMiddleware(mid1).
+		Post(rightHandler, mid2)
+	s.Route("/files/*").Any(rightHandler)
+	s.Route("/put").Put(rightHandler)
+	s.RouteHost("example.com", "/").Get(wrongHandler)
+	return s
+}
+
+// explainDebug explains the request with the debug handler's JSON
+func explainDebug(t *testing.T, s *ServeMux, method, host, path string) *explanation {
+	query := url.Values{"method": {method}, "host": {host}, "path": {path}, "format": {"json"}}
+	rec := httptest.NewRecorder()
+	DebugHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatal("Wrong status", rec.Code, rec.Body.String())
+	}
+
+	page := &debugPage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Explain == nil {
+		t.Fatal("Request not explained")
+	}
+	return page.Explain
+}
+
+func TestDebugHandler_Explain(t *testing.T) {
+	s := debugMux()
+	s.Route("/").Middleware(Tag(mid2, "audit"))
+
+	e := explainDebug(t, s, http.MethodGet, "", "/users/andrew")
+
+	var patterns []string
+	for _, node := range e.Nodes {
+		patterns = append(patterns, node.Pattern+"="+node.Kind+":"+node.Segment)
+	}
+	if strings.Join(patterns, " ") != "/=root: /users=literal:users /users/:id=param:andrew" {
+		t.Error("Wrong nodes", patterns)
+	}
+
+	users := e.Nodes[1]
+	if strings.Join(users.Collected, ",") != "powermux.dummyHandler" ||
+		strings.Join(users.Filtered, ",") != "powermux.dummyHandler (POST)" ||
+		strings.Join(users.Excluded, ",") != "powermux.dummyHandler" {
+		t.Error("Wrong middleware", users.Collected, users.Filtered, users.Excluded)
+	}
+
+	id := e.Nodes[2]
+	if strings.Join(id.Collected, ",") != "powermux.dummyHandler [local]" {
+		t.Error("Wrong local middleware", id.Collected)
+	}
+	if strings.Join(users.Tried, ",") != `literal: no literal children,param: captured "andrew" as id` ||
+		users.Stop != "" || id.Stop != "end of path" {
+		t.Error("Wrong attempts", users.Tried, users.Stop, id.Stop)
+	}
+
+	if len(e.Params) != 1 || e.Params[0].Name != "id" || e.Params[0].Value != "andrew" {
+		t.Error("Wrong params", e.Params)
+	}
+	if e.Kind != "route" || e.Pattern != "/users/:id" || e.Choice != "explicit handler" ||
+		e.Handler != "powermux.dummyHandler" || len(e.Chain) != 2 {
+		t.Error("Wrong result", e.Kind, e.Pattern, e.Choice, e.Handler, e.Chain)
+	}
+
+	// handler bound middleware
+	e = explainDebug(t, s, http.MethodPost, "", "/users/andrew")
+	if id := e.Nodes[2]; strings.Join(id.Collected, ",") != "powermux.dummyHandler [local],powermux.dummyHandler [POST handler]" {
+		t.Error("Wrong bound middleware", id.Collected)
+	}
+}
+
+func TestDebugHandler_Choices(t *testing.T) {
+	s := debugMux()
+
+	tests := []struct {
+		method string
+		host   string
+		path   string
+		kind   string
+		choice string
+	}{
+		{http.MethodGet, "", "/users/andrew", "route", "explicit handler"},
+		{http.MethodHead, "", "/users/andrew", "route", "HEAD served by GET handler"},
+		{http.MethodDelete, "", "/files/a/b", "route", "ANY handler"},
+		{http.MethodGet, "", "/put", "method not allowed", "generated 405 Method Not Allowed response"},
+		{http.MethodOptions, "", "/put", "options", "OPTIONS handler inherited from /"},
+		{http.MethodGet, "", "/missing", "not found", "NotFound handler"},
+		{http.MethodGet, "", "/users/", "redirect", "trailing slash redirect"},
+		{http.MethodGet, "", "//users", "bad request", "bad request handler"},
+		{http.MethodGet, "example.com", "/", "route", "explicit handler"},
+	}
+
+	for _, test := range tests {
+		e := explainDebug(t, s, test.method, test.host, test.path)
+		if e.Kind != test.kind || e.Choice != test.choice {
+			t.Error("Wrong result for", test.method, test.path, e.Kind, e.Choice)
+		}
+		if e.HostTree != test.host {
+			t.Error("Wrong host tree for", test.path, e.HostTree)
+		}
+	}
+
+	// generated OPTIONS responses without an inherited handler
+	s = NewServeMux()
+	s.Route("/a").Get(rightHandler)
+	if e := explainDebug(t, s, http.MethodOptions, "", "/a"); e.Choice != "generated OPTIONS response" {
+		t.Error("Wrong OPTIONS result", e.Choice)
+	}
+}
+
+func TestDebugHandler_Listing(t *testing.T) {
+	s := debugMux()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+	req.Header.Set("Accept", "application/json")
+	DebugHandler(s).ServeHTTP(rec, req)
+
+	page := &debugPage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Routes) != 7 || page.Explain != nil {
+		t.Error("Wrong listing", len(page.Routes), page.Explain)
+	}
+
+	rec = httptest.NewRecorder()
+	DebugHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug?path=/users/%3Cb%3E", nil))
+	body := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Error("Wrong content type", rec.Header().Get("Content-Type"))
+	}
+	for _, s := range []string{"<form", "/users/:id", "id=&lt;b&gt;", "example.com"} {
+		if !strings.Contains(body, s) {
+			t.Error("Listing missing", s)
+		}
+	}
+	if strings.Contains(body, "<b>") {
+		t.Error("Path not escaped")
+	}
+
+	rec = httptest.NewRecorder()
+	DebugHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug?method=BREW&path=/", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Error("Unknown method explained", rec.Code)
+	}
+}
@@ -0,0 +1,227 @@
+package powermux
+
+import (
+	"errors"
+	"net/http"
+)
+
+// HandlerE is a handler that returns an error instead of writing the error response itself.
+// Errors are passed to the ErrorHandler that applies to the route serving the request.
+type HandlerE func(http.ResponseWriter, *http.Request) error
+
+// ServeHTTP calls h(w, r), and passes any error it returns to the error handler.
+func (h HandlerE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		handleError(w, r, err, defaultErrorHandler{})
+	}
+}
+
+// ErrorHandler writes the response for an error returned by a HandlerE.
+type ErrorHandler interface {
+	ServeHTTPError(http.ResponseWriter, *http.Request, error)
+}
+
+// The ErrorHandlerFunc type is an adapter to allow the use of ordinary functions as error handlers.
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request, error)
+
+// ServeHTTPError calls f(w, r, err).
+func (f ErrorHandlerFunc) ServeHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	f(w, r, err)
+}
+
+// StatusError is an error with the status code its response should have.
+type StatusError struct {
+	// Status is the HTTP status code of the response
+	Status int
+	// Err is the underlying error, if any
+	Err error
+}
+
+// Error returns the underlying error's message, or the status text if there isn't one.
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.Status)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status code of the response.
+func (e *StatusError) StatusCode() int {
+	return e.Status
+}
+
+// statusOf returns the status code chosen by the error or any error it wraps,
+// or the fallback if none of them choose one
+func statusOf(err error, fallback int) int {
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	return fallback
+}
+
+// defaultErrorHandler responds with the status chosen by the error, or http.StatusInternalServerError.
+// The error's message is never sent to the client.
+type defaultErrorHandler struct{}
+
+// ServeHTTPError writes the status text, or a problem if problem responses are enabled
+func (defaultErrorHandler) ServeHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err, http.StatusInternalServerError)
+
+	if ex := lookupExecution(r); ex != nil && ex.renderer != nil {
+		ex.renderer.RenderProblem(w, r, newProblem(r, status))
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// handleError records the error for the request and passes it to the error handler for the route,
+// or the fallback if the route doesn't have one
+func handleError(w http.ResponseWriter, r *http.Request, err error, fallback ErrorHandler) {
+	ex := lookupExecution(r)
+	if ex == nil {
+		fallback.ServeHTTPError(w, r, err)
+		return
+	}
+
+	ex.handlerErr = err
+	if ex.errorHandler != nil {
+		ex.errorHandler.ServeHTTPError(w, r, err)
+		return
+	}
+	fallback.ServeHTTPError(w, r, err)
+}
+
+// HandlerError returns the error the handler serving the request returned, or nil if it didn't return one.
+// Middleware can call it once the next function returns to observe errors.
+func HandlerError(req *http.Request) error {
+	ex := getRequestExecution(req)
+	return ex.handlerErr
+}
+
+// ErrorHandler sets the handler for errors returned by HandlerE handlers.
+// This handler will also be called for any routes further down the path
+// from this point if no other error handlers are registered below.
+func (r *Route) ErrorHandler(handler ErrorHandler) *Route {
+	r.errorHandler = handler
+	r.version.invalidate()
+	return r
+}
+
+// ErrorHandlerFunc sets a plain function as the handler for errors returned by HandlerE handlers.
+// This handler will also be called for any routes further down the path
+// from this point if no other error handlers are registered below.
+func (r *Route) ErrorHandlerFunc(f ErrorHandlerFunc) *Route {
+	return r.ErrorHandler(f)
+}
+
+// ErrorHandler sets the default handler for errors returned by HandlerE handlers.
+func (s *ServeMux) ErrorHandler(handler ErrorHandler) {
+	s.baseRoute.ErrorHandler(handler)
+}
+
+// AnyE registers an error returning handler for any method sent to this route.
+// This takes lower precedence than a specific method match.
+func (r *Route) AnyE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Any(handler, mids...)
+}
+
+// PostE adds an error returning handler for POST methods to this route.
+func (r *Route) PostE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Post(handler, mids...)
+}
+
+// PutE adds an error returning handler for PUT methods to this route.
+func (r *Route) PutE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Put(handler, mids...)
+}
+
+// PatchE adds an error returning handler for PATCH methods to this route.
+func (r *Route) PatchE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Patch(handler, mids...)
+}
+
+// GetE adds an error returning handler for GET methods to this route.
+// GET handlers will also be called for HEAD requests
+// if no specific HEAD handler is registered.
+func (r *Route) GetE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Get(handler, mids...)
+}
+
+// DeleteE adds an error returning handler for DELETE methods to this route.
+func (r *Route) DeleteE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Delete(handler, mids...)
+}
+
+// HeadE adds an error returning handler for HEAD methods to this route.
+func (r *Route) HeadE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Head(handler, mids...)
+}
+
+// ConnectE adds an error returning handler for CONNECT methods to this route.
+func (r *Route) ConnectE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Connect(handler, mids...)
+}
+
+// OptionsE adds an error returning handler for OPTIONS methods to this route.
+// This handler will also be called for any routes further down the path
+// from this point if no other OPTIONS handlers are registered below.
+func (r *Route) OptionsE(handler HandlerE, mids ...Middleware) *Route {
+	return r.Options(handler, mids...)
+}
+
+// AnyE registers an error returning handler for any method sent to the group's route.
+// This takes lower precedence than a specific method match.
+func (g *Group) AnyE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Any(handler, mids...)
+}
+
+// PostE adds an error returning handler for POST methods to the group's route.
+func (g *Group) PostE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Post(handler, mids...)
+}
+
+// PutE adds an error returning handler for PUT methods to the group's route.
+func (g *Group) PutE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Put(handler, mids...)
+}
+
+// PatchE adds an error returning handler for PATCH methods to the group's route.
+func (g *Group) PatchE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Patch(handler, mids...)
+}
+
+// GetE adds an error returning handler for GET methods to the group's route.
+// GET handlers will also be called for HEAD requests
+// if no specific HEAD handler is registered.
+func (g *Group) GetE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Get(handler, mids...)
+}
+
+// DeleteE adds an error returning handler for DELETE methods to the group's route.
+func (g *Group) DeleteE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Delete(handler, mids...)
+}
+
+// HeadE adds an error returning handler for HEAD methods to the group's route.
+func (g *Group) HeadE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Head(handler, mids...)
+}
+
+// ConnectE adds an error returning handler for CONNECT methods to the group's route.
+func (g *Group) ConnectE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Connect(handler, mids...)
+}
+
+// OptionsE adds an error returning handler for OPTIONS methods to the group's route.
+// This handler will also be called for any routes further down the path
+// from this point if no other OPTIONS handlers are registered below,
+// along with the group's middleware.
+func (g *Group) OptionsE(handler HandlerE, mids ...Middleware) *Group {
+	return g.Options(handler, mids...)
+}
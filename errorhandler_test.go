@@ -0,0 +1,130 @@
+package powermux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func errorHandlerE(err error) HandlerE {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return err
+	}
+}
+
+func TestHandlerE_Default(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/ok").GetE(func(w http.ResponseWriter, r *http.Request) error {
+		io.WriteString(w, "ok")
+		return nil
+	})
+	s.Route("/fail").GetE(errorHandlerE(errors.New("secret")))
+	s.Route("/status").GetE(errorHandlerE(&StatusError{Status: http.StatusNotFound}))
+	s.Route("/wrapped").GetE(errorHandlerE(fmt.Errorf("loading: %w", &StatusError{
+		Status: http.StatusConflict,
+		Err:    errors.New("secret"),
+	})))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", http.StatusOK, "ok"},
+		{"/fail", http.StatusInternalServerError, "Internal Server Error\n"},
+		{"/status", http.StatusNotFound, "Not Found\n"},
+		{"/wrapped", http.StatusConflict, "Conflict\n"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Error("Wrong response for", test.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandlerE_Inherited(t *testing.T) {
+	s := NewServeMux()
+
+	handled := func(name string) ErrorHandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusTeapot)
+			io.WriteString(w, name+": "+err.Error())
+		}
+	}
+
+	s.ErrorHandler(handled("root"))
+	s.Route("/api").ErrorHandlerFunc(handled("api"))
+	s.Route("/a").GetE(errorHandlerE(errors.New("a")))
+	s.Route("/api/users/:id").GetE(errorHandlerE(errors.New("user")))
+
+	tests := map[string]string{
+		"/a":             "root: a",
+		"/api/users/bob": "api: user",
+	}
+
+	for path, body := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusTeapot || rec.Body.String() != body {
+			t.Error("Wrong error handler for", path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandlerE_Middleware(t *testing.T) {
+	s := NewServeMux()
+	returned := errors.New("failed")
+
+	var observed []error
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		n(w, r)
+		observed = append(observed, HandlerError(r))
+	})
+	s.Route("/a").GetE(errorHandlerE(returned))
+	s.Route("/b").Get(rightHandler)
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/b", nil))
+
+	if len(observed) != 2 || observed[0] != returned || observed[1] != nil {
+		t.Error("Wrong errors observed", observed)
+	}
+}
+
+func TestHandlerE_Problem(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+	s.Route("/a").GetE(errorHandlerE(&StatusError{Status: http.StatusUnprocessableEntity}))
+
+	rec, body := serveProblem(t, s, httptest.NewRequest(http.MethodGet, "/a", nil))
+	if rec.Code != http.StatusUnprocessableEntity || body["title"] != "Unprocessable Entity" {
+		t.Error("Wrong problem", rec.Code, body)
+	}
+}
+
+func TestHandlerE_WithoutServeMux(t *testing.T) {
+	rec := httptest.NewRecorder()
+	errorHandlerE(&StatusError{Status: http.StatusBadGateway}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Error("Wrong status", rec.Code)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := &StatusError{Status: http.StatusNotFound}
+	if err.Error() != "Not Found" {
+		t.Error("Wrong message", err.Error())
+	}
+
+	inner := errors.New("missing")
+	err = &StatusError{Status: http.StatusNotFound, Err: inner}
+	if err.Error() != "missing" || !errors.Is(err, inner) {
+		t.Error("Underlying error not used", err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package powermux
+
+// RoutingErrorKind identifies what was wrong with a request path
+type RoutingErrorKind int
+
+const (
+	// ErrDoubleSlash indicates the path contained an empty segment, such as "/users//info"
+	ErrDoubleSlash RoutingErrorKind = iota + 1
+	// ErrInvalidEscape indicates a path parameter could not be unescaped
+	ErrInvalidEscape
+	// ErrConstraint indicates a path parameter did not satisfy the constraint of its route
+	ErrConstraint
+	// ErrParamParse indicates a path parameter could not be converted by the parser of its route
+	ErrParamParse
+)
+
+// String returns a short description of the kind of error
+func (k RoutingErrorKind) String() string {
+	switch k {
+	case ErrDoubleSlash:
+		return "double slash"
+	case ErrInvalidEscape:
+		return "invalid escape"
+	case ErrConstraint:
+		return "constraint failure"
+	case ErrParamParse:
+		return "parameter parse failure"
+	default:
+		return "unknown"
+	}
+}
+
+// RoutingError explains why the router rejected a request path.
+// It is available to bad request handlers through RequestError.
+type RoutingError struct {
+	// Kind is what was wrong with the path
+	Kind RoutingErrorKind
+	// Path is the escaped request path
+	Path string
+	// Segment is the offending path segment, if any
+	Segment string
+	// Param is the name of the path parameter the segment was matched to, if any
+	Param string
+	// Err is the underlying error, if any
+	Err error
+}
+
+// Error implements the error interface
+func (e *RoutingError) Error() string {
+	msg := "powermux: " + e.Kind.String() + " in path " + e.Path
+	if e.Param != "" {
+		msg += " for parameter " + e.Param
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *RoutingError) Unwrap() error {
+	return e.Err
+}
@@ -1,33 +1,270 @@
 package powermux
 
 import (
+	"context"
 	"net/http"
 	"sync"
 )
 
+// pathParam is the value of a path parameter for a request
+type pathParam struct {
+	name  string
+	value string
+	// the value converted by the route's parser, if it has one
+	parsed interface{}
+}
+
+// requestContext attaches an execution to a request's context, without allocating a new context
+type requestContext struct {
+	context.Context
+	ex *routeExecution
+}
+
+// Value returns the execution for the execution key, and defers to the request's context for anything else
+func (c *requestContext) Value(key interface{}) interface{} {
+	if key == executionKey {
+		return c.ex
+	}
+	return c.Context.Value(key)
+}
+
+// servedContext is the parent of pooled request contexts once their request has been served.
+// It's already canceled, as the context of a served request would be.
+var servedContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
 // routeExecution is the complete instructions for running serve on a route
 type routeExecution struct {
 	pattern    string
-	params     map[string]string
+	params     []pathParam
 	notFound   http.Handler
 	middleware []Middleware
-	handler    http.Handler
+	conditions []Predicate
+	// the number of conditional middleware collected, and which of them had predicates that held
+	conditional int
+	held        uint64
+	handler     http.Handler
+	allowed     []string
+	cors        *CORS
+	badRequest  http.Handler
+	err         *RoutingError
+	problem     problemResponse
+	kind        MatchKind
+	route       *Route
+	node        *Route
+	host        string
+	method      string
+	renderer    ProblemRenderer
+	// the requirements collected for the request, and the handler enforcing them
+	requirements []string
+	auth         authorizedHandler
+	// the route and key of the registered handler chosen, if any
+	endpoint    *Route
+	endpointKey string
+	// the context and request the handler is served with
+	ctx requestContext
+	req http.Request
+	// set if the request escaped the handler, so the execution can't be reused
+	detached bool
+	// the handler for errors returned by the handler, and the error returned
+	errorHandler ErrorHandler
+	handlerErr   error
+	// the response writer handlers are served with when recovering from panics
+	writer recoveryWriter
+	// records the routes visited, only when explaining a request
+	trace *matchTrace
+	// the routes suggested for a request that wasn't found
+	suggestions []Suggestion
+	// the literal routes matched for the path, visited in order while routing
+	literals []*Route
 }
 
 func newExecution() *routeExecution {
-	return &routeExecution{
-		middleware: make([]Middleware, 0),
-		params:     make(map[string]string),
+	ex := &routeExecution{
+		middleware:   make([]Middleware, 0),
+		conditions:   make([]Predicate, 0),
+		params:       make([]pathParam, 0),
+		requirements: make([]string, 0),
+		literals:     make([]*Route, 0),
 	}
+	ex.ctx = requestContext{
+		Context: servedContext,
+		ex:      ex,
+	}
+	return ex
 }
 
 func (ex *routeExecution) Reset() {
 	ex.middleware = ex.middleware[0:0]
-	for key := range ex.params {
-		delete(ex.params, key)
+	for i := range ex.conditions {
+		ex.conditions[i] = nil
+	}
+	ex.conditions = ex.conditions[0:0]
+	ex.conditional = 0
+	ex.held = 0
+	for i := range ex.params {
+		ex.params[i] = pathParam{}
 	}
+	ex.params = ex.params[0:0]
 	ex.handler = nil
 	ex.notFound = nil
+	ex.allowed = nil
+	ex.cors = nil
+	ex.badRequest = nil
+	ex.err = nil
+	ex.problem = problemResponse{}
+	ex.pattern = ""
+	ex.kind = MatchNotFound
+	ex.route = nil
+	ex.node = nil
+	for i := range ex.literals {
+		ex.literals[i] = nil
+	}
+	ex.literals = ex.literals[0:0]
+	ex.host = ""
+	ex.method = ""
+	ex.renderer = nil
+	ex.requirements = ex.requirements[0:0]
+	ex.auth = authorizedHandler{}
+	ex.setEndpoint(nil, "")
+	ex.ctx.Context = servedContext
+	ex.req = http.Request{}
+	ex.errorHandler = nil
+	ex.handlerErr = nil
+	ex.writer = recoveryWriter{}
+	ex.trace = nil
+	ex.suggestions = nil
+}
+
+// copyRouting copies the results of routing a request from another execution
+func (ex *routeExecution) copyRouting(from *routeExecution) {
+	ex.pattern = from.pattern
+	ex.params = append(ex.params[0:0], from.params...)
+	ex.notFound = from.notFound
+	ex.middleware = append(ex.middleware[0:0], from.middleware...)
+	ex.conditions = append(ex.conditions[0:0], from.conditions...)
+	ex.handler = from.handler
+	ex.allowed = from.allowed
+	ex.cors = from.cors
+	ex.badRequest = from.badRequest
+	ex.err = from.err
+	ex.kind = from.kind
+	ex.route = from.route
+	ex.node = from.node
+	ex.requirements = append(ex.requirements[0:0], from.requirements...)
+	ex.setEndpoint(from.endpoint, from.endpointKey)
+	ex.errorHandler = from.errorHandler
+}
+
+// attach returns a copy of the request that carries the execution in its context.
+// The copy belongs to the execution, and is only valid until the execution is reset.
+func (ex *routeExecution) attach(req *http.Request) *http.Request {
+	ex.ctx.Context = req.Context()
+	ex.req = *req.WithContext(&ex.ctx)
+	return &ex.req
+}
+
+// setParam saves the value of a path parameter
+func (ex *routeExecution) setParam(name, value string) {
+	ex.params = append(ex.params, pathParam{
+		name:  name,
+		value: value,
+	})
+}
+
+// param returns the value of a path parameter.
+// If the name is used more than once in the path, the last value is returned.
+func (ex *routeExecution) param(name string) string {
+	for i := len(ex.params) - 1; i >= 0; i-- {
+		if ex.params[i].name == name {
+			return ex.params[i].value
+		}
+	}
+	return ""
+}
+
+// parsedParam returns the parsed value of a path parameter.
+// If the name is used more than once in the path, the last value is returned.
+func (ex *routeExecution) parsedParam(name string) interface{} {
+	for i := len(ex.params) - 1; i >= 0; i-- {
+		if ex.params[i].name == name {
+			return ex.params[i].parsed
+		}
+	}
+	return nil
+}
+
+// addMiddleware adds a middleware to be executed, along with the condition it is executed under
+func (ex *routeExecution) addMiddleware(mid *middlewareForVerb) {
+	ex.middleware = append(ex.middleware, mid.mid)
+	ex.conditions = append(ex.conditions, mid.cond)
+}
+
+// skipMiddleware removes all the middleware the skip excludes
+func (ex *routeExecution) skipMiddleware(skip *middlewareSkip) {
+	kept := 0
+	for i, m := range ex.middleware {
+		if !skip.Excludes(m) {
+			ex.middleware[kept] = m
+			ex.conditions[kept] = ex.conditions[i]
+			kept++
+		}
+	}
+	ex.middleware = ex.middleware[0:kept]
+	ex.conditions = ex.conditions[0:kept]
+}
+
+// applyConditions evaluates the conditions of all conditional middleware, removing the middleware
+// that should not be executed, and records which conditions held. It must be called once the request
+// has been routed.
+func (ex *routeExecution) applyConditions(req *http.Request) {
+	if !ex.hasConditions() {
+		return
+	}
+
+	var match Match
+	matched := false
+	kept := 0
+	for i, m := range ex.middleware {
+		if cond := ex.conditions[i]; cond != nil {
+			if !matched {
+				match = ex.match()
+				matched = true
+			}
+			held := cond(req, match)
+			if held && ex.conditional < 64 {
+				ex.held |= 1 << uint(ex.conditional)
+			}
+			ex.conditional++
+			if !held {
+				continue
+			}
+		}
+		ex.middleware[kept] = m
+		ex.conditions[kept] = nil
+		kept++
+	}
+	ex.middleware = ex.middleware[0:kept]
+	ex.conditions = ex.conditions[0:kept]
+}
+
+// hasConditions reports if any of the middleware is conditional
+func (ex *routeExecution) hasConditions() bool {
+	for _, cond := range ex.conditions {
+		if cond != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setEndpoint records which registered handler was chosen
+func (ex *routeExecution) setEndpoint(route *Route, key string) {
+	ex.endpoint = route
+	ex.endpointKey = key
 }
 
 type executionPool struct {
@@ -39,6 +276,10 @@ func (ep *executionPool) Get() *routeExecution {
 }
 
 func (ep *executionPool) Put(ex *routeExecution) {
+	// detached executions are left to the requests that still use them
+	if ex.detached {
+		return
+	}
 	ex.Reset()
 	ep.p.Put(ex)
 }
@@ -0,0 +1,263 @@
+package powermux
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// routeJSON is a route in the JSON route table
+type routeJSON struct {
+	Host       string           `json:"host,omitempty"`
+	Pattern    string           `json:"pattern"`
+	Params     []string         `json:"params,omitempty"`
+	Wildcard   bool             `json:"wildcard,omitempty"`
+	Methods    []methodJSON     `json:"methods,omitempty"`
+	Middleware []middlewareJSON `json:"middleware,omitempty"`
+	NotFound   string           `json:"notFound,omitempty"`
+	Options    string           `json:"options,omitempty"`
+}
+
+// methodJSON is a handler in the JSON route table
+type methodJSON struct {
+	Method     string           `json:"method"`
+	Handler    string           `json:"handler"`
+	Request    string           `json:"request,omitempty"`
+	Response   string           `json:"response,omitempty"`
+	Middleware []middlewareJSON `json:"middleware,omitempty"`
+}
+
+// middlewareJSON is a middleware in the JSON route table
+type middlewareJSON struct {
+	Middleware  string   `json:"middleware"`
+	Methods     []string `json:"methods,omitempty"`
+	Local       bool     `json:"local,omitempty"`
+	Conditional bool     `json:"conditional,omitempty"`
+}
+
+// ExportJSON writes every route as a JSON document, in the order they are visited by Walk.
+// Handlers and middleware are named by their function or type.
+func (s *ServeMux) ExportJSON(w io.Writer) error {
+	routes, err := s.routeTable()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Routes []routeJSON `json:"routes"`
+	}{
+		Routes: routes,
+	})
+}
+
+// routeTable describes every route, in the order they are visited by Walk
+func (s *ServeMux) routeTable() ([]routeJSON, error) {
+	routes := make([]routeJSON, 0)
+	err := s.Walk(func(info RouteInfo) error {
+		route := routeJSON{
+			Host:       info.Host,
+			Pattern:    info.Pattern,
+			Params:     info.Params,
+			Wildcard:   info.Wildcard,
+			Middleware: middlewareJSONs(info.Middleware),
+			NotFound:   describe(info.NotFound),
+			Options:    describe(info.Options),
+		}
+		for _, m := range info.allMethods() {
+			method := methodJSON{
+				Method:     m.Method,
+				Handler:    describe(m.Handler),
+				Middleware: middlewareJSONs(m.Middleware),
+			}
+			if types, ok := info.Route.types[m.Method]; ok {
+				method.Request = types.request.String()
+				method.Response = types.response.String()
+			}
+			route.Methods = append(route.Methods, method)
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	return routes, err
+}
+
+// middlewareJSONs converts the middleware for the JSON route table
+func middlewareJSONs(mids []MiddlewareInfo) []middlewareJSON {
+	if len(mids) == 0 {
+		return nil
+	}
+	out := make([]middlewareJSON, 0, len(mids))
+	for _, mid := range mids {
+		out = append(out, middlewareJSON{
+			Middleware:  describe(mid.Middleware),
+			Methods:     mid.Methods,
+			Local:       mid.Local,
+			Conditional: mid.Conditional,
+		})
+	}
+	return out
+}
+
+// ExportTree writes every route as an indented tree, one route per line, with each route's handlers and
+// middleware. Path parameter and wildcard routes are marked.
+func (s *ServeMux) ExportTree(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	err := s.Walk(func(info RouteInfo) error {
+		depth := 0
+		segment := info.Host + "/"
+		if info.Pattern != "/" {
+			depth = strings.Count(info.Pattern, "/")
+			segment = info.Pattern[strings.LastIndex(info.Pattern, "/")+1:]
+		}
+
+		buf.WriteString(strings.Repeat("  ", depth))
+		buf.WriteString(segment)
+		if info.Route.isParam {
+			buf.WriteString(" (param)")
+		}
+		if info.Wildcard {
+			buf.WriteString(" (wildcard)")
+		}
+
+		if methods := info.allMethods(); len(methods) > 0 {
+			names := make([]string, 0, len(methods))
+			for _, m := range methods {
+				names = append(names, m.Method)
+			}
+			buf.WriteString(" [" + strings.Join(names, ", ") + "]")
+		}
+
+		if len(info.Middleware) > 0 {
+			names := make([]string, 0, len(info.Middleware))
+			for _, mid := range info.Middleware {
+				names = append(names, describeMiddleware(mid))
+			}
+			buf.WriteString(" middleware: " + strings.Join(names, ", "))
+		}
+
+		buf.WriteString("\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// ExportDOT writes every route as a Graphviz DOT graph, with edges from each route to the routes below it.
+// Middleware are drawn as separate nodes with dashed edges to the routes and handlers they are added to.
+func (s *ServeMux) ExportDOT(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString("digraph routes {\n")
+	buf.WriteString("\tnode [shape=box];\n")
+
+	ids := make(map[*Route]string)
+	mids := 0
+
+	err := s.Walk(func(info RouteInfo) error {
+		id := fmt.Sprintf("r%d", len(ids))
+		ids[info.Route] = id
+
+		label := []string{info.Host + info.Pattern}
+		for _, m := range info.allMethods() {
+			label = append(label, m.Method+" "+describe(m.Handler))
+		}
+		fmt.Fprintf(buf, "\t%s [label=\"%s\"];\n", id, dotLabel(label...))
+
+		if parent, ok := ids[info.Route.parent]; ok {
+			fmt.Fprintf(buf, "\t%s -> %s;\n", parent, id)
+		}
+
+		attach := func(mid MiddlewareInfo, edge string) {
+			midID := fmt.Sprintf("m%d", mids)
+			mids++
+			fmt.Fprintf(buf, "\t%s [label=\"%s\", shape=ellipse];\n", midID, dotLabel(describe(mid.Middleware)))
+			fmt.Fprintf(buf, "\t%s -> %s [style=dashed, label=\"%s\"];\n", midID, id, dotLabel(edge))
+		}
+		for _, mid := range info.Middleware {
+			attach(mid, middlewareScope(mid))
+		}
+		for _, m := range info.allMethods() {
+			for _, mid := range m.Middleware {
+				attach(mid, m.Method+" handler")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString("}\n")
+	return buf.Flush()
+}
+
+// dotLabel joins the lines into an escaped DOT label
+func dotLabel(lines ...string) string {
+	escaped := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, `\`, `\\`)
+		line = strings.ReplaceAll(line, `"`, `\"`)
+		escaped = append(escaped, line)
+	}
+	return strings.Join(escaped, `\n`)
+}
+
+// allMethods returns the handlers for specific methods followed by the handler for any method
+func (info *RouteInfo) allMethods() []MethodInfo {
+	if info.Any == nil {
+		return info.Methods
+	}
+	methods := make([]MethodInfo, 0, len(info.Methods)+1)
+	methods = append(methods, info.Methods...)
+	return append(methods, *info.Any)
+}
+
+// describeMiddleware names the middleware along with where it applies
+func describeMiddleware(mid MiddlewareInfo) string {
+	name := describe(mid.Middleware)
+	if scope := middlewareScope(mid); scope != "" {
+		return name + " (" + scope + ")"
+	}
+	return name
+}
+
+// middlewareScope describes where the middleware applies, or is empty if it always does
+func middlewareScope(mid MiddlewareInfo) string {
+	var scope []string
+	if mid.Methods != nil {
+		scope = append(scope, strings.Join(mid.Methods, ","))
+	}
+	if mid.Local {
+		scope = append(scope, "local")
+	}
+	if mid.Conditional {
+		scope = append(scope, "conditional")
+	}
+	return strings.Join(scope, ", ")
+}
+
+// describe names a handler or middleware by its function, or by its type
+func describe(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if m, ok := v.(Middleware); ok {
+		v = untag(m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(rv.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,147 @@
+package powermux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func exportMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/users").
+		Middleware(mid1).
+		LocalMiddlewareFor(mid2, http.MethodPost)
+	s.Route("/users/:id").
+		Get(JSON(func(ctx context.Context, req jsonRequest) (jsonResponse, error) {
+			return jsonResponse{}, nil
+		}), Tag(mid2, "tagged")).
+		Any(rightHandler)
+	s.Route("/files/*").Get(http.NotFoundHandler())
+	s.RouteHost("example.com", "/a").Post(rightHandler)
+	return s
+}
+
+func TestServeMux_ExportJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := exportMux().ExportJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var table struct {
+		Routes []routeJSON `json:"routes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &table); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns := make([]string, 0, len(table.Routes))
+	for _, route := range table.Routes {
+		patterns = append(patterns, route.Host+route.Pattern)
+	}
+	if strings.Join(patterns, " ") != "/ /files /files/* /users /users/:id example.com/ example.com/a" {
+		t.Fatal("Wrong routes", patterns)
+	}
+
+	if root := table.Routes[0]; root.NotFound != "powermux.notFoundHandler" {
+		t.Error("Wrong not found handler", root.NotFound)
+	}
+
+	users := table.Routes[3]
+	if len(users.Middleware) != 2 || users.Middleware[0].Middleware != "powermux.dummyHandler" ||
+		!users.Middleware[1].Local || strings.Join(users.Middleware[1].Methods, ",") != "POST" {
+		t.Error("Wrong middleware", users.Middleware)
+	}
+
+	id := table.Routes[4]
+	if strings.Join(id.Params, ",") != "id" || len(id.Methods) != 2 {
+		t.Fatal("Wrong route", id)
+	}
+	get, anyMethod := id.Methods[0], id.Methods[1]
+	if get.Method != http.MethodGet || get.Request != "powermux.jsonRequest" || get.Response != "powermux.jsonResponse" {
+		t.Error("Wrong typed handler", get)
+	}
+	if len(get.Middleware) != 1 || get.Middleware[0].Middleware != "powermux.dummyHandler" {
+		t.Error("Wrong bound middleware", get.Middleware)
+	}
+	if anyMethod.Method != "ANY" || anyMethod.Handler != "powermux.dummyHandler" || anyMethod.Request != "" {
+		t.Error("Wrong any handler", anyMethod)
+	}
+
+	if files := table.Routes[2]; !files.Wildcard || files.Methods[0].Handler != "net/http.NotFound" {
+		t.Error("Wrong wildcard route", files)
+	}
+}
+
+func TestServeMux_ExportTree(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := exportMux().ExportTree(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `/
+  files
+    * (wildcard) [GET]
+  users middleware: powermux.dummyHandler, powermux.dummyHandler (POST, local)
+    :id (param) [GET, ANY]
+example.com/
+  a [POST]
+`
+	if buf.String() != expected {
+		t.Errorf("Wrong tree:\n%s", buf.String())
+	}
+}
+
+func TestServeMux_ExportDOT(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := exportMux().ExportDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+
+	for _, line := range []string{
+		"digraph routes {",
+		`r4 [label="/users/:id\nGET *powermux.jsonHandler[`,
+		"r3 -> r4;",
+		`m1 -> r3 [style=dashed, label="POST, local"];`,
+		`m2 -> r4 [style=dashed, label="GET handler"];`,
+		`r6 [label="example.com/a\nPOST powermux.dummyHandler"];`,
+		"r5 -> r6;",
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("DOT missing %s:\n%s", line, dot)
+		}
+	}
+	if strings.Contains(dot, "-> r5") {
+		t.Error("Host tree attached to another tree")
+	}
+}
+
+func TestServeMux_ExportStable(t *testing.T) {
+	s := exportMux()
+	for _, host := range []string{"b.com", "c.com", "a.com", "d.com"} {
+		s.RouteHost(host, "/").Get(rightHandler).Post(rightHandler).Delete(rightHandler).Put(rightHandler)
+	}
+
+	export := func() string {
+		buf := &bytes.Buffer{}
+		s.ExportJSON(buf)
+		s.ExportTree(buf)
+		s.ExportDOT(buf)
+		buf.WriteString(s.String())
+		return buf.String()
+	}
+
+	first := export()
+	for i := 0; i < 10; i++ {
+		if export() != first {
+			t.Fatal("Exports differ")
+		}
+	}
+
+	if !strings.Contains(s.String(), "a.com/\t[DELETE, GET, POST, PUT]\nb.com/") {
+		t.Error("String not sorted", s.String())
+	}
+}
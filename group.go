@@ -0,0 +1,233 @@
+package powermux
+
+import (
+	"net/http"
+)
+
+// A Group registers handlers and sub-routes on a Route that share middleware, without the middleware
+// applying to anything else registered on the Route.
+//
+// Middleware added to a group applies to the handlers and sub-routes registered through the group after it.
+// It's executed at the route the group was created on, after the middleware of the routes above it and before
+// the route's own middleware.
+type Group struct {
+	route      *Route
+	middleware []*groupMiddleware
+}
+
+// groupMiddleware is a middleware added to a group, and the route it's executed at
+type groupMiddleware struct {
+	mid    *middlewareForVerb
+	anchor *Route
+}
+
+// Group calls f with a new group on this route.
+//
+// Middleware added to the group is only executed for handlers and sub-routes registered through the group,
+// not for other handlers on this route or its other children.
+func (r *Route) Group(f func(g *Group)) *Route {
+	f(&Group{route: r})
+	return r
+}
+
+// Group calls f with a new group nested in this group.
+// The nested group starts with all the middleware of this group.
+func (g *Group) Group(f func(g *Group)) *Group {
+	nested := &Group{
+		route:      g.route,
+		middleware: make([]*groupMiddleware, len(g.middleware)),
+	}
+	copy(nested.middleware, g.middleware)
+	f(nested)
+	return g
+}
+
+// add adds a middleware to this group, executed at the group's route
+func (g *Group) add(mid *middlewareForVerb) *Group {
+	if mid != nil {
+		g.middleware = append(g.middleware, &groupMiddleware{
+			mid:    mid,
+			anchor: g.route,
+		})
+	}
+	return g
+}
+
+// Middleware adds a middleware to this group.
+func (g *Group) Middleware(m Middleware) *Group {
+	return g.add(&middlewareForVerb{
+		mid:  m,
+		verb: flagAny,
+	})
+}
+
+// MiddlewareFor adds a middleware to this group, but will only be executed
+// for requests with the verb specified.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (g *Group) MiddlewareFor(m Middleware, verbs ...string) *Group {
+	return g.add(newMiddlewareFor(m, verbs))
+}
+
+// MiddlewareExceptFor adds a middleware to this group, but will only be executed
+// for requests that are not in the list of verbs.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (g *Group) MiddlewareExceptFor(m Middleware, verbs ...string) *Group {
+	return g.add(newMiddlewareExceptFor(m, verbs))
+}
+
+// MiddlewareFunc registers a plain function as a middleware.
+func (g *Group) MiddlewareFunc(m MiddlewareFunc) *Group {
+	return g.Middleware(MiddlewareFunc(m))
+}
+
+// Use adds a standard func(http.Handler) http.Handler middleware to this group.
+func (g *Group) Use(m func(http.Handler) http.Handler) *Group {
+	return g.Middleware(Adapt(m))
+}
+
+// Route walks down the route tree from the group's route following pattern, and returns a group for the
+// node that represents that path. The new group starts with all the middleware of this group, so it's
+// executed for the handlers registered through it, but not for other handlers on the node or the routes
+// below it. The middleware is still executed at the route this group was created on.
+func (g *Group) Route(path string) *Group {
+	nested := &Group{
+		route:      g.route.Route(path),
+		middleware: make([]*groupMiddleware, len(g.middleware)),
+	}
+	copy(nested.middleware, g.middleware)
+	return nested
+}
+
+// handle registers the handler on the group's route with the group's middleware and the handler's own
+// middleware bound to it
+func (g *Group) handle(method string, handler http.Handler, mids []Middleware) *Group {
+	g.route.setHandler(method, handler)
+	g.route.bindGroupMiddleware(method, g.middleware)
+	g.route.bindMiddleware(method, boundMiddleware(mids))
+	return g
+}
+
+// bindGroupMiddleware binds the middleware of a group to the handler for the method
+func (r *Route) bindGroupMiddleware(method string, mids []*groupMiddleware) {
+	if len(mids) == 0 {
+		return
+	}
+	if r.groupMiddleware == nil {
+		r.groupMiddleware = make(map[string][]*groupMiddleware)
+	}
+	r.groupMiddleware[method] = append(r.groupMiddleware[method], mids...)
+	r.version.invalidate()
+}
+
+// Any registers a catch-all handler for any method sent to the group's route.
+// This takes lower precedence than a specific method match.
+func (g *Group) Any(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(methodAny, handler, mids)
+}
+
+// AnyFunc registers a plain function as a catch-all handler
+// for any method sent to the group's route.
+// This takes lower precedence than a specific method match.
+func (g *Group) AnyFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Any(http.HandlerFunc(f), mids...)
+}
+
+// Post adds a handler for POST methods to the group's route.
+func (g *Group) Post(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodPost, handler, mids)
+}
+
+// PostFunc adds a plain function as a handler
+// for POST methods to the group's route.
+func (g *Group) PostFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Post(http.HandlerFunc(f), mids...)
+}
+
+// Put adds a handler for PUT methods to the group's route.
+func (g *Group) Put(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodPut, handler, mids)
+}
+
+// PutFunc adds a plain function as a handler
+// for PUT methods to the group's route.
+func (g *Group) PutFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Put(http.HandlerFunc(f), mids...)
+}
+
+// Patch adds a handler for PATCH methods to the group's route.
+func (g *Group) Patch(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodPatch, handler, mids)
+}
+
+// PatchFunc adds a plain function as a handler
+// for PATCH methods to the group's route.
+func (g *Group) PatchFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Patch(http.HandlerFunc(f), mids...)
+}
+
+// Get adds a handler for GET methods to the group's route.
+// GET handlers will also be called for HEAD requests
+// if no specific HEAD handler is registered.
+func (g *Group) Get(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodGet, handler, mids)
+}
+
+// GetFunc adds a plain function as a handler
+// for GET methods to the group's route.
+// GET handlers will also be called for HEAD requests
+// if no specific HEAD handler is registered.
+func (g *Group) GetFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Get(http.HandlerFunc(f), mids...)
+}
+
+// Delete adds a handler for DELETE methods to the group's route.
+func (g *Group) Delete(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodDelete, handler, mids)
+}
+
+// DeleteFunc adds a plain function as a handler
+// for DELETE methods to the group's route.
+func (g *Group) DeleteFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Delete(http.HandlerFunc(f), mids...)
+}
+
+// Head adds a handler for HEAD methods to the group's route.
+func (g *Group) Head(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodHead, handler, mids)
+}
+
+// HeadFunc adds a plain function as a handler
+// for HEAD methods to the group's route.
+func (g *Group) HeadFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Head(http.HandlerFunc(f), mids...)
+}
+
+// Connect adds a handler for CONNECT methods to the group's route.
+func (g *Group) Connect(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodConnect, handler, mids)
+}
+
+// ConnectFunc adds a plain function as a handler
+// for CONNECT methods to the group's route.
+func (g *Group) ConnectFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Connect(http.HandlerFunc(f), mids...)
+}
+
+// Options adds a handler for OPTIONS methods to the group's route.
+// This handler will also be called for any routes further down the path
+// from this point if no other OPTIONS handlers are registered below,
+// along with the group's middleware.
+func (g *Group) Options(handler http.Handler, mids ...Middleware) *Group {
+	return g.handle(http.MethodOptions, handler, mids)
+}
+
+// OptionsFunc adds a plain function as a handler
+// for OPTIONS methods to the group's route.
+// This handler will also be called for any routes further down the path
+// from this point if no other OPTIONS handlers are registered below,
+// along with the group's middleware.
+func (g *Group) OptionsFunc(f http.HandlerFunc, mids ...Middleware) *Group {
+	return g.Options(http.HandlerFunc(f), mids...)
+}
@@ -0,0 +1,148 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute_Group(t *testing.T) {
+	s := NewServeMux()
+
+	users := s.Route("/users").Middleware(mid1)
+	users.Post(rightHandler)
+	users.Group(func(g *Group) {
+		g.Middleware(mid2)
+		g.Get(rightHandler)
+		g.Route("/:id").Get(rightHandler)
+	})
+	users.Route("/search").Get(rightHandler)
+	users.Route("/:id").Post(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/users", body: "mid2mid1right"},
+		{method: http.MethodHead, path: "/users", body: "mid2mid1right"},
+		{method: http.MethodPost, path: "/users", body: "mid1right"},
+		{method: http.MethodGet, path: "/users/andrew", body: "mid2mid1right"},
+		{method: http.MethodPost, path: "/users/andrew", body: "mid1right"},
+		{method: http.MethodGet, path: "/users/search", body: "mid1right"},
+		{method: http.MethodPut, path: "/users", body: "mid1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Body.String() != tt.body {
+				t.Errorf("Wrong execution, expected=%s actual=%s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoute_GroupNested(t *testing.T) {
+	s := NewServeMux()
+
+	mid3 := dummyHandler("mid3")
+
+	s.Route("/").Group(func(g *Group) {
+		g.MiddlewareFor(mid1, http.MethodPost)
+		g.Route("/a").Get(rightHandler).Post(rightHandler)
+		g.Group(func(g *Group) {
+			g.Middleware(mid2)
+			g.Route("/a").Put(rightHandler)
+			g.Route("/b").Get(rightHandler)
+		})
+		g.Middleware(mid3)
+		g.Route("/c").Get(rightHandler)
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/a", body: "right"},
+		{method: http.MethodPost, path: "/a", body: "mid1right"},
+		{method: http.MethodPut, path: "/a", body: "mid2right"},
+		{method: http.MethodGet, path: "/b", body: "mid2right"},
+		{method: http.MethodGet, path: "/c", body: "mid3right"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Body.String() != tt.body {
+				t.Errorf("Wrong execution, expected=%s actual=%s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoute_GroupReplacedHandler(t *testing.T) {
+	s := NewServeMux()
+
+	r := s.Route("/a")
+	r.Group(func(g *Group) {
+		g.Middleware(mid1)
+		g.Get(wrongHandler)
+	})
+	r.Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "right" {
+		t.Error("Group middleware should not apply to a replaced handler", rec.Body.String())
+	}
+}
+
+func TestRoute_GroupOrder(t *testing.T) {
+	s := NewServeMux()
+
+	root := dummyHandler("root")
+	path := dummyHandler("path")
+	group := dummyHandler("group")
+	inner := dummyHandler("inner")
+	local := dummyHandler("local")
+	handler := dummyHandler("handler")
+
+	s.Route("/").Middleware(root)
+	api := s.Route("/api").Middleware(path)
+	api.Route("/users").LocalMiddleware(local)
+	api.Group(func(g *Group) {
+		g.Middleware(group)
+		g.Get(rightHandler)
+		g.Route("/users").Middleware(inner).Get(rightHandler, handler)
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		// group middleware runs where the group was created, before the middleware of that route
+		{path: "/api", body: "rootgrouppathright"},
+		{path: "/api/users", body: "rootgrouppathinnerlocalhandlerright"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Body.String() != tt.body {
+				t.Errorf("Wrong execution, expected=%s actual=%s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package powermux
 import (
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -29,25 +30,111 @@ func (h methodNotAllowedHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusMethodNotAllowed)
 }
 
+// problem describes a Method Not Allowed response, including the valid methods for this route.
+func (h methodNotAllowedHandler) problem(w http.ResponseWriter, r *http.Request, _ *routeExecution) *Problem {
+	w.Header().Add("Allow", strings.Join(h, ", "))
+	p := newProblem(r, http.StatusMethodNotAllowed)
+	p.Extensions = map[string]interface{}{
+		"allowed": []string(h),
+	}
+	return p
+}
+
+type notFoundHandler struct{}
+
+// ServeHTTP replies with a plain text 404 not found error.
+func (notFoundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+// problem describes a Not Found response
+func (notFoundHandler) problem(_ http.ResponseWriter, r *http.Request, _ *routeExecution) *Problem {
+	return newProblem(r, http.StatusNotFound)
+}
+
+// trailingSlashHandler redirects to the path without a trailing slash
+type trailingSlashHandler string
+
+// ServeHTTP redirects to the canonical path with a permanent redirect
+func (h trailingSlashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, string(h), http.StatusPermanentRedirect)
+}
+
+// problem describes the redirect to the canonical path
+func (h trailingSlashHandler) problem(w http.ResponseWriter, r *http.Request, _ *routeExecution) *Problem {
+	w.Header().Set("Location", string(h))
+	p := newProblem(r, http.StatusPermanentRedirect)
+	p.Extensions = map[string]interface{}{
+		"location": string(h),
+	}
+	return p
+}
+
 type defaultOptionsHandler struct {
 	methods []string
 }
 
+// ServeHTTP responds to an OPTIONS request with No Content and an "Allow" header containing the
+// valid methods for this route.
+func (h *defaultOptionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(h.methods, ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // methodNotAllowed is called internally by Route to generate a 405 handler
 func (r *Route) methodNotAllowed() http.Handler {
 
-	// determine what methods ARE supported by this route
-	methods := make([]string, 0, 8)
+	// 405 only makes sense if some methods are allowed, which are the same ones OPTIONS advertises
+	if len(r.methods) > 0 {
+		return methodNotAllowedHandler(r.allowed)
+	}
+
+	return nil
+}
+
+// allowedMethods is called internally by Route to determine every method this route will answer.
+// A GET handler implies HEAD, OPTIONS is always answered, and an ANY handler answers everything.
+func (r *Route) allowedMethods() []string {
+	if _, ok := r.handlers[methodAny]; ok {
+		return []string{
+			http.MethodConnect,
+			http.MethodDelete,
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodOptions,
+			http.MethodPatch,
+			http.MethodPost,
+			http.MethodPut,
+		}
+	}
+
+	// nothing to offer
+	if len(r.methods) == 0 {
+		return nil
+	}
 
-	for method := range r.handlers {
-		if method != methodAny && method != notFound {
-			methods = append(methods, method)
+	methods := make([]string, 0, len(r.methods)+2)
+	methods = append(methods, r.methods...)
+
+	if _, ok := r.handlers[http.MethodGet]; ok {
+		if _, ok := r.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
 		}
 	}
+	if _, ok := r.handlers[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// defaultOptions is called internally by Route to generate an OPTIONS handler
+func (r *Route) defaultOptions() http.Handler {
 
-	// 405 only makes sense if some methods are allowed
-	if len(methods) > 0 {
-		return methodNotAllowedHandler(methods)
+	// an OPTIONS response only makes sense if some methods are allowed
+	if len(r.allowed) > 0 {
+		return &defaultOptionsHandler{methods: r.allowed}
 	}
 
 	return nil
@@ -61,6 +148,18 @@ func (brh badRequestHandler) ServeHTTP(res http.ResponseWriter, _ *http.Request)
 	io.WriteString(res, string(brh))
 }
 
-func (r *Route) badRequest(errMsg string) http.Handler{
-	return badRequestHandler(errMsg)
-}
\ No newline at end of file
+// problem describes a bad request, including the reason the path was rejected
+func (brh badRequestHandler) problem(_ http.ResponseWriter, r *http.Request, ex *routeExecution) *Problem {
+	p := newProblem(r, http.StatusBadRequest)
+	p.Detail = string(brh)
+	if ex.err != nil {
+		p.Detail = ex.err.Error()
+		p.Extensions = map[string]interface{}{
+			"reason": ex.err.Kind.String(),
+		}
+	}
+	return p
+}
+
+// defaultBadRequest is used for malformed paths when no other bad request handler is registered
+var defaultBadRequest = badRequestHandler("Invalid path")
@@ -40,10 +40,65 @@ func TestRoute_MethodNotAllowed(t *testing.T) {
 		allowedMethods[allow] = true
 	}
 
-	if !allowedMethods[http.MethodGet] || !allowedMethods[http.MethodDelete] {
+	if !allowedMethods[http.MethodGet] || !allowedMethods[http.MethodDelete] ||
+		!allowedMethods[http.MethodHead] || !allowedMethods[http.MethodOptions] {
 		t.Error("Did not allow all required methods")
 	}
-	if len(allowedMethods) > 2 {
+	if len(allowedMethods) > 4 {
 		t.Error("Excessive methods allowed")
 	}
+
+	// the generated OPTIONS response advertises the same methods
+	r.getHandler(http.MethodOptions, ex)
+	opts := httptest.NewRecorder()
+	ex.handler.ServeHTTP(opts, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if opts.Header().Get("Allow") != rec.Header().Get("Allow") {
+		t.Error("Allow headers differ", opts.Header().Get("Allow"), rec.Header().Get("Allow"))
+	}
+}
+
+func TestRoute_DefaultOptions(t *testing.T) {
+	r := newRoute()
+
+	r.Get(http.NotFoundHandler())
+	r.Post(http.NotFoundHandler())
+
+	ex := &routeExecution{}
+
+	r.getHandler(http.MethodOptions, ex)
+
+	if ex.handler == nil {
+		t.Fatal("Nil handler returned")
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+
+	ex.handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Error("Wrong status returned", rec.Code)
+	}
+
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Error("Wrong methods allowed", allow)
+	}
+}
+
+func TestRoute_DefaultOptionsAny(t *testing.T) {
+	r := newRoute()
+
+	r.Any(rightHandler)
+
+	ex := &routeExecution{}
+
+	r.getHandler(http.MethodOptions, ex)
+
+	if ex.handler != rightHandler {
+		t.Error("ANY handler should take precedence over generated OPTIONS")
+	}
+
+	if len(ex.allowed) != len(allMethods) {
+		t.Error("ANY handler should allow all methods", ex.allowed)
+	}
 }
@@ -0,0 +1,365 @@
+package powermux
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validator is implemented by request types that check their own values.
+// Errors returned by Validate are answered with http.StatusUnprocessableEntity unless they choose a status.
+type Validator interface {
+	Validate() error
+}
+
+// MaxJSONBodySize is the largest request body, in bytes, handlers created with JSON will decode.
+// Larger bodies are answered with http.StatusRequestEntityTooLarge.
+var MaxJSONBodySize int64 = 1 << 20
+
+// typedHandler is implemented by handlers that know the types of the requests and responses they serve
+type typedHandler interface {
+	handlerTypes() (request, response reflect.Type)
+}
+
+// handlerTypes are the request and response types of a typed handler
+type handlerTypes struct {
+	request  reflect.Type
+	response reflect.Type
+}
+
+// jsonHandler serves a typed function as a JSON endpoint
+type jsonHandler[Req, Resp any] struct {
+	f func(context.Context, Req) (Resp, error)
+}
+
+// JSON creates a handler that serves the function as a JSON endpoint.
+//
+// The request value is decoded from the JSON body, if there is one, up to MaxJSONBodySize bytes. A null body
+// is treated like no body at all. Fields of a struct request tagged with
+// `path:"name"` or `query:"name"` are then set from the path parameter or query values of that name.
+// Request types that implement Validator are validated before the function is called.
+//
+// The response is encoded as JSON with http.StatusOK, or the status chosen by the response's
+// `StatusCode() int` method if it has one.
+//
+// Errors, including malformed requests, go to the ErrorHandler for the route if there is one, and are otherwise
+// answered with problems. The problem's detail is the error's message for client errors only.
+//
+// The request and response types are recorded on the routes the handler is registered on.
+func JSON[Req, Resp any](f func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+	return &jsonHandler[Req, Resp]{f: f}
+}
+
+// handlerTypes returns the request and response types
+func (h *jsonHandler[Req, Resp]) handlerTypes() (request, response reflect.Type) {
+	return reflect.TypeOf((*Req)(nil)).Elem(), reflect.TypeOf((*Resp)(nil)).Elem()
+}
+
+// ServeHTTP decodes the request, calls the function and encodes its response
+func (h *jsonHandler[Req, Resp]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.serve(w, r); err != nil {
+		handleError(w, r, err, problemErrorHandler{})
+	}
+}
+
+// serve does the work of ServeHTTP, returning any error
+func (h *jsonHandler[Req, Resp]) serve(w http.ResponseWriter, r *http.Request) error {
+	var req Req
+
+	// decode into a new value when the request is a pointer
+	ptr := reflect.ValueOf(&req).Elem()
+	target := ptr
+	if target.Kind() == reflect.Ptr {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	// a null body sets the pointer back to nil, so point it at the new value again
+	if ptr.Kind() == reflect.Ptr && ptr.IsNil() {
+		ptr.Set(target.Addr())
+	}
+
+	if target.Kind() == reflect.Struct {
+		if err := bindRequest(r, target); err != nil {
+			return err
+		}
+	}
+
+	if err := validate(&req); err != nil {
+		return err
+	}
+
+	resp, err := h.f(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	// a nil pointer can't choose its status
+	status := http.StatusOK
+	if sc, ok := interface{}(resp).(statusCoder); ok && !isNilPointer(resp) {
+		status = sc.StatusCode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+	return nil
+}
+
+// isNilPointer reports if the value is a nil pointer
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body, counting the bytes read
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeJSON decodes the request body into v, if there is a body no larger than MaxJSONBodySize
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return &StatusError{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("unsupported content type %q", ct),
+			}
+		}
+	}
+
+	tooLarge := &StatusError{
+		Status: http.StatusRequestEntityTooLarge,
+		Err:    fmt.Errorf("request body larger than %d bytes", MaxJSONBodySize),
+	}
+	if r.ContentLength > MaxJSONBodySize {
+		return tooLarge
+	}
+
+	// the body is read through the limit, so reading more than the limit from it means it was too large
+	body := &countingReader{ReadCloser: r.Body}
+	if err := json.NewDecoder(http.MaxBytesReader(w, body, MaxJSONBodySize)).Decode(v); err != nil && err != io.EOF {
+		if body.n > MaxJSONBodySize {
+			return tooLarge
+		}
+		return &StatusError{
+			Status: http.StatusBadRequest,
+			Err:    fmt.Errorf("invalid request body: %w", err),
+		}
+	}
+	return nil
+}
+
+// bindRequest sets the fields of the struct tagged with path or query from the request
+func bindRequest(r *http.Request, v reflect.Value) error {
+	ex := lookupExecution(r)
+	var query map[string][]string
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// unexported fields can't be set
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name, ok := field.Tag.Lookup("path"); ok && ex != nil {
+			for _, p := range ex.params {
+				if p.name != name {
+					continue
+				}
+				if err := setField(v.Field(i), []string{ex.param(name)}); err != nil {
+					return bindError("path parameter", name, err)
+				}
+				break
+			}
+		}
+
+		if name, ok := field.Tag.Lookup("query"); ok {
+			if query == nil {
+				query = r.URL.Query()
+			}
+			if values := query[name]; len(values) > 0 {
+				if err := setField(v.Field(i), values); err != nil {
+					return bindError("query parameter", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// bindError describes a value that couldn't be bound as a bad request
+func bindError(source, name string, err error) error {
+	return &StatusError{
+		Status: http.StatusBadRequest,
+		Err:    fmt.Errorf("invalid %s %q: %w", source, name, err),
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setField parses the values into the field.
+// Only slices use more than the first value.
+func setField(field reflect.Value, values []string) error {
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setField(slice.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	case reflect.String:
+		field.SetString(values[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(values[0], field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// validate calls Validate on the request if it has it, with either receiver
+func validate(req interface{}) error {
+	v, ok := req.(Validator)
+	if !ok {
+		v, ok = reflect.ValueOf(req).Elem().Interface().(Validator)
+	}
+	if !ok {
+		return nil
+	}
+
+	err := v.Validate()
+	if err == nil {
+		return nil
+	}
+
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return err
+	}
+	return &StatusError{
+		Status: http.StatusUnprocessableEntity,
+		Err:    err,
+	}
+}
+
+// problemErrorHandler answers errors with problems, using the renderer for the host or ProblemJSON.
+// The error's message is only sent as the detail of client errors.
+type problemErrorHandler struct{}
+
+// ServeHTTPError renders the error as a problem
+func (problemErrorHandler) ServeHTTPError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err, http.StatusInternalServerError)
+
+	p := newProblem(r, status)
+	if status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+
+	renderer := ProblemJSON
+	if ex := lookupExecution(r); ex != nil && ex.renderer != nil {
+		renderer = ex.renderer
+	}
+	renderer.RenderProblem(w, r, p)
+}
+
+// setTypes records the request and response types of the handler for the method, if it's a typed handler
+func (r *Route) setTypes(method string, handler http.Handler) {
+	th, ok := handler.(typedHandler)
+	if !ok {
+		delete(r.types, method)
+		return
+	}
+
+	if r.types == nil {
+		r.types = make(map[string]handlerTypes)
+	}
+	req, resp := th.handlerTypes()
+	r.types[method] = handlerTypes{
+		request:  req,
+		response: resp,
+	}
+}
+
+// HandlerTypes returns the request and response types of the handler that serves the method on this route,
+// if it was created with JSON.
+// HEAD requests use the types of the GET handler if there isn't a HEAD handler, and handlers registered with
+// Any are used for methods without their own handler.
+func (r *Route) HandlerTypes(method string) (request, response reflect.Type, ok bool) {
+	key := method
+	if _, exists := r.handlers[key]; !exists && method == http.MethodHead {
+		key = http.MethodGet
+	}
+	if _, exists := r.handlers[key]; !exists {
+		key = methodAny
+	}
+
+	types, ok := r.types[key]
+	return types.request, types.response, ok
+}
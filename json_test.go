@@ -0,0 +1,288 @@
+package powermux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonRequest struct {
+	Name  string     `json:"name"`
+	ID    int        `path:"id"`
+	Tags  []string   `query:"tag"`
+	Limit *uint8     `query:"limit"`
+	Since *time.Time `query:"since"`
+	Debug bool       `query:"debug"`
+}
+
+func (r jsonRequest) Validate() error {
+	if r.Name == "invalid" {
+		return errors.New("name is invalid")
+	}
+	if r.Name == "forbidden" {
+		return &StatusError{Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+type jsonResponse struct {
+	Greeting string `json:"greeting"`
+	status   int
+}
+
+func (r jsonResponse) StatusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func jsonGreeter(ctx context.Context, req jsonRequest) (jsonResponse, error) {
+	switch req.Name {
+	case "missing":
+		return jsonResponse{}, &StatusError{Status: http.StatusNotFound, Err: errors.New("no such name")}
+	case "broken":
+		return jsonResponse{}, errors.New("secret")
+	case "new":
+		return jsonResponse{Greeting: "welcome", status: http.StatusCreated}, nil
+	}
+
+	greeting := "hello " + req.Name + " " + PathParam(httpRequest(ctx), "id")
+	if req.Limit != nil {
+		greeting += " limit"
+	}
+	if req.Since != nil {
+		greeting += " " + req.Since.Format("2006")
+	}
+	if req.Debug {
+		greeting += " debug"
+	}
+	return jsonResponse{Greeting: greeting + " " + strings.Join(req.Tags, ",")}, nil
+}
+
+type requestKey struct{}
+
+// httpRequest returns the request stored in the context by the test middleware
+func httpRequest(ctx context.Context) *http.Request {
+	return ctx.Value(requestKey{}).(*http.Request)
+}
+
+func storeRequest(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+	n(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, r)))
+}
+
+func TestJSON(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/users/:id").Middleware(MiddlewareFunc(storeRequest)).Post(JSON(jsonGreeter))
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		response    string
+	}{
+		{"Bound", "/users/4?tag=a&tag=b&limit=3&since=2020-01-02T00:00:00Z&debug=true", "application/json",
+			`{"name":"bob"}`, http.StatusOK, `{"greeting":"hello bob 4 limit 2020 debug a,b"}`},
+		{"NoBody", "/users/4", "", "", http.StatusOK, `{"greeting":"hello  4 "}`},
+		{"NoContentType", "/users/4", "", `{"name":"bob"}`, http.StatusOK, `{"greeting":"hello bob 4 "}`},
+		{"ProblemContentType", "/users/4", "application/merge-patch+json; charset=utf-8", `{"name":"bob"}`,
+			http.StatusOK, `{"greeting":"hello bob 4 "}`},
+		{"ResponseStatus", "/users/4", "", `{"name":"new"}`, http.StatusCreated, `{"greeting":"welcome"}`},
+		{"UnsupportedType", "/users/4", "text/plain", `{"name":"bob"}`, http.StatusUnsupportedMediaType, ""},
+		{"BadBody", "/users/4", "application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"BadPath", "/users/four", "", "", http.StatusBadRequest, ""},
+		{"BadQuery", "/users/4?limit=300", "", "", http.StatusBadRequest, ""},
+		{"BadText", "/users/4?since=yesterday", "", "", http.StatusBadRequest, ""},
+		{"Invalid", "/users/4", "", `{"name":"invalid"}`, http.StatusUnprocessableEntity, ""},
+		{"InvalidStatus", "/users/4", "", `{"name":"forbidden"}`, http.StatusForbidden, ""},
+		{"Error", "/users/4", "", `{"name":"missing"}`, http.StatusNotFound, ""},
+		{"InternalError", "/users/4", "", `{"name":"broken"}`, http.StatusInternalServerError, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatal("Wrong status", rec.Code, rec.Body.String())
+			}
+
+			if test.response != "" {
+				if rec.Header().Get("Content-Type") != "application/json" {
+					t.Error("Wrong content type", rec.Header().Get("Content-Type"))
+				}
+				if rec.Body.String() != test.response {
+					t.Error("Wrong response", rec.Body.String())
+				}
+				return
+			}
+
+			if rec.Header().Get("Content-Type") != "application/problem+json" {
+				t.Fatal("Error not sent as a problem", rec.Header().Get("Content-Type"))
+			}
+			problem := make(map[string]interface{})
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem["status"] != float64(test.status) {
+				t.Error("Wrong problem status", problem["status"])
+			}
+			_, hasDetail := problem["detail"]
+			if hasDetail != (test.status < http.StatusInternalServerError) {
+				t.Error("Wrong problem detail", problem["detail"])
+			}
+			if strings.Contains(rec.Body.String(), "secret") {
+				t.Error("Internal error message sent")
+			}
+		})
+	}
+}
+
+func TestJSON_Pointers(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/:id").Get(JSON(func(ctx context.Context, req *jsonRequest) (*jsonResponse, error) {
+		if req.ID == 0 {
+			return nil, nil
+		}
+		return &jsonResponse{Greeting: req.Name}, nil
+	}))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/2", strings.NewReader(`{"name":"bob"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"greeting":"bob"}` {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/2", strings.NewReader(`{"name":"invalid"}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Error("Pointer request not validated", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/0", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "null" {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/2", strings.NewReader(`null`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"greeting":""}` {
+		t.Error("Wrong response for null body", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJSON_TooLarge(t *testing.T) {
+	defer func(size int64) { MaxJSONBodySize = size }(MaxJSONBodySize)
+	MaxJSONBodySize = 16
+
+	s := NewServeMux()
+	s.Route("/users/:id").Middleware(MiddlewareFunc(storeRequest)).Post(JSON(jsonGreeter))
+
+	tests := map[string]struct {
+		body   string
+		length int64
+		status int
+	}{
+		"Limit":         {`{"name":"bobby"}`, 16, http.StatusOK},
+		"TooLarge":      {`{"name":"robert"}`, 17, http.StatusRequestEntityTooLarge},
+		"UnknownLength": {`{"name":"robert"}`, -1, http.StatusRequestEntityTooLarge},
+		"Malformed":     {`{"name":`, -1, http.StatusBadRequest},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/4", strings.NewReader(test.body))
+			req.ContentLength = test.length
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Error("Wrong status", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSON_ErrorHandler(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, err.Error())
+	})
+
+	var handlerErr error
+	s.Route("/a").Middleware(MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		n(w, r)
+		handlerErr = HandlerError(r)
+	})).Post(JSON(jsonGreeter))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/a", strings.NewReader(`{"name":"broken"}`)))
+	if rec.Code != http.StatusTeapot || rec.Body.String() != "secret" {
+		t.Error("Inherited error handler not used", rec.Code, rec.Body.String())
+	}
+	if handlerErr == nil || handlerErr.Error() != "secret" {
+		t.Error("Error not recorded", handlerErr)
+	}
+}
+
+func TestJSON_Renderer(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemRendererFunc(func(w http.ResponseWriter, r *http.Request, p *Problem) {
+		w.WriteHeader(p.Status)
+		io.WriteString(w, "custom "+p.Detail)
+	}))
+	s.Route("/").Post(JSON(jsonGreeter))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"missing"}`)))
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "custom no such name" {
+		t.Error("Problem renderer not used", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoute_HandlerTypes(t *testing.T) {
+	s := NewServeMux()
+	route := s.Route("/a").
+		Get(JSON(jsonGreeter)).
+		Any(JSON(func(ctx context.Context, req *jsonRequest) ([]int, error) { return nil, nil }))
+
+	request, response, ok := route.HandlerTypes(http.MethodGet)
+	if !ok || request != reflect.TypeOf(jsonRequest{}) || response != reflect.TypeOf(jsonResponse{}) {
+		t.Error("Wrong GET types", request, response, ok)
+	}
+
+	request, _, ok = route.HandlerTypes(http.MethodHead)
+	if !ok || request != reflect.TypeOf(jsonRequest{}) {
+		t.Error("HEAD didn't use GET types", request, ok)
+	}
+
+	request, response, ok = route.HandlerTypes(http.MethodPost)
+	if !ok || request != reflect.TypeOf(&jsonRequest{}) || response != reflect.TypeOf([]int{}) {
+		t.Error("POST didn't use ANY types", request, response, ok)
+	}
+
+	// replacing a typed handler with a plain one forgets its types
+	route.Get(rightHandler)
+	if _, _, ok := route.HandlerTypes(http.MethodGet); ok {
+		t.Error("Types kept for plain handler")
+	}
+	if _, _, ok := s.Route("/b").HandlerTypes(http.MethodGet); ok {
+		t.Error("Types for route without handlers")
+	}
+}
@@ -0,0 +1,181 @@
+package powermux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	lifetimeWorkers  = 8
+	lifetimeRequests = 200
+)
+
+// checkRouting verifies the routing details a request was served with match the ones it expects
+func checkRouting(t *testing.T, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	pattern := r.URL.Query().Get("pattern")
+
+	if got := PathParam(r, "id"); got != id {
+		t.Errorf("Param leaked between requests, wanted %q got %q", id, got)
+	}
+	if got := PathParams(r); len(got) != 1 || got["id"] != id {
+		t.Errorf("Params leaked between requests, wanted %q got %v", id, got)
+	}
+	if got := RequestPath(r); got != pattern {
+		t.Errorf("Request path leaked between requests, wanted %q got %q", pattern, got)
+	}
+}
+
+func lifetimeMux(handler http.HandlerFunc) *ServeMux {
+	s := NewServeMux()
+	s.Route("/users/:id").Get(handler)
+	s.Route("/users/:id/files/*").Get(handler)
+	s.Route("/teams/:id/members").Get(handler)
+	return s
+}
+
+// lifetimeRequest builds a request whose query holds the routing details it should be served with
+func lifetimeRequest(worker, i int) *http.Request {
+	id := strconv.Itoa(worker) + "-" + strconv.Itoa(i)
+	var path, pattern string
+	switch i % 3 {
+	case 0:
+		path, pattern = "/users/"+id, "/users/:id"
+	case 1:
+		path, pattern = "/users/"+id+"/files/a/b", "/users/:id/files/*"
+	default:
+		path, pattern = "/teams/"+id+"/members", "/teams/:id/members"
+	}
+	return httptest.NewRequest(http.MethodGet, path+"?id="+id+"&pattern="+pattern, nil)
+}
+
+// serveConcurrently serves requests to the mux from several goroutines at once
+func serveConcurrently(s *ServeMux) {
+	wg := sync.WaitGroup{}
+	for w := 0; w < lifetimeWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lifetimeRequests; i++ {
+				s.ServeHTTP(httptest.NewRecorder(), lifetimeRequest(w, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestLifetime_ConcurrentRequests(t *testing.T) {
+	s := lifetimeMux(func(w http.ResponseWriter, r *http.Request) {
+		checkRouting(t, r)
+	})
+	serveConcurrently(s)
+}
+
+func TestLifetime_DetachParams(t *testing.T) {
+	wg := sync.WaitGroup{}
+	release := make(chan struct{})
+
+	s := lifetimeMux(func(w http.ResponseWriter, r *http.Request) {
+		r = DetachParams(r)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+			checkRouting(t, r)
+		}()
+	})
+
+	// every handler returns before any of the goroutines read their request
+	serveConcurrently(s)
+	close(release)
+	wg.Wait()
+}
+
+func TestLifetime_DetachedRequest(t *testing.T) {
+	type key string
+
+	wg := sync.WaitGroup{}
+	release := make(chan struct{})
+
+	s := lifetimeMux(func(w http.ResponseWriter, r *http.Request) {
+		r = DetachParams(r)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+
+			// the request and its context are kept along with the routing details
+			if id := r.URL.Query().Get("id"); r.URL.Path != "/users/"+id &&
+				r.URL.Path != "/users/"+id+"/files/a/b" && r.URL.Path != "/teams/"+id+"/members" {
+				t.Errorf("Request reused, %s is not for %s", r.URL.Path, id)
+			}
+			if r.Context().Value(key("id")) != r.URL.Query().Get("id") || r.Context().Err() != nil {
+				t.Error("Request context reused", r.Context().Value(key("id")), r.Context().Err())
+			}
+		}()
+	})
+
+	// every handler returns before any of the goroutines read their request
+	wgServe := sync.WaitGroup{}
+	for w := 0; w < lifetimeWorkers; w++ {
+		wgServe.Add(1)
+		go func(w int) {
+			defer wgServe.Done()
+			for i := 0; i < lifetimeRequests; i++ {
+				req := lifetimeRequest(w, i)
+				ctx := context.WithValue(req.Context(), key("id"), req.URL.Query().Get("id"))
+				s.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+			}
+		}(w)
+	}
+	wgServe.Wait()
+	close(release)
+	wg.Wait()
+}
+
+func TestLifetime_DetachParamsDerived(t *testing.T) {
+	type key string
+
+	done := make(chan struct{})
+	once := sync.Once{}
+	var detached *http.Request
+
+	s := lifetimeMux(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			detached = DetachParams(r)
+		})
+	})
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		n(w, r.WithContext(context.WithValue(r.Context(), key("k"), "v")))
+	})
+
+	s.ServeHTTP(httptest.NewRecorder(), lifetimeRequest(0, 0))
+
+	go func() {
+		defer close(done)
+		serveConcurrently(s)
+	}()
+
+	checkRouting(t, detached)
+	if detached.Context().Value(key("k")) != "v" {
+		t.Error("Detached request lost its context values")
+	}
+	<-done
+}
+
+func TestLifetime_Reset(t *testing.T) {
+	r := newRoute()
+	r.Route("/users/:id").Get(wrongHandler)
+
+	ex := newExecution()
+	r.execute(ex, http.MethodGet, "/users/andrew")
+	ex.Reset()
+
+	if ex.pattern != "" || ex.param("id") != "" || len(ex.params) != 0 {
+		t.Error("Execution not reset", ex.pattern, ex.params)
+	}
+}
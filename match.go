@@ -0,0 +1,77 @@
+package powermux
+
+import "net/http"
+
+// MatchKind describes how the router resolved a request
+type MatchKind int
+
+const (
+	// MatchNotFound means no route matched and the not found handler was used
+	MatchNotFound MatchKind = iota
+	// MatchRoute means a handler registered on the matched route was used
+	MatchRoute
+	// MatchOptions means an OPTIONS request was answered by an inherited or generated OPTIONS handler
+	MatchOptions
+	// MatchMethodNotAllowed means the route matched but has no handler for the method
+	MatchMethodNotAllowed
+	// MatchRedirect means the path had a trailing slash and was redirected.
+	// Redirects are answered before the routes are searched, so no middleware runs for them.
+	MatchRedirect
+	// MatchBadRequest means the path was malformed and the bad request handler was used
+	MatchBadRequest
+)
+
+// String returns the name of the match kind
+func (k MatchKind) String() string {
+	switch k {
+	case MatchNotFound:
+		return "not found"
+	case MatchRoute:
+		return "route"
+	case MatchOptions:
+		return "options"
+	case MatchMethodNotAllowed:
+		return "method not allowed"
+	case MatchRedirect:
+		return "redirect"
+	case MatchBadRequest:
+		return "bad request"
+	default:
+		return "unknown"
+	}
+}
+
+// Match describes how the router resolved a request
+type Match struct {
+	// Kind is the outcome of routing the request
+	Kind MatchKind
+	// Route is the route node the path resolved to, or nil if it did not resolve to one
+	Route *Route
+	// Pattern is the path definition used to route the request, as returned by RequestPath
+	Pattern string
+	// Host is the host whose routes were used, or empty if the default routes were used
+	Host string
+	// Allowed is the list of methods supported by the route, as returned by AllowedMethods.
+	// It must not be modified.
+	Allowed []string
+}
+
+// match builds the description of the execution
+func (ex *routeExecution) match() Match {
+	return Match{
+		Kind:    ex.kind,
+		Route:   ex.route,
+		Pattern: ex.pattern,
+		Host:    ex.host,
+		Allowed: ex.allowed,
+	}
+}
+
+// MatchInfo describes how the router resolved the request.
+//
+// Middleware runs for every request, including those served by generated handlers, and can use this
+// to tell them apart from requests served by a registered route.
+func MatchInfo(req *http.Request) Match {
+	ex := getRequestExecution(req)
+	return ex.match()
+}
@@ -0,0 +1,83 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchInfo(t *testing.T) {
+	s := NewServeMux()
+
+	var match Match
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		match = MatchInfo(r)
+		n(w, r)
+	})
+
+	users := s.Route("/users/:id").Get(rightHandler)
+	s.Route("/docs").Options(rightHandler)
+	s.RouteHost("example.com", "/").Get(rightHandler).MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		match = MatchInfo(r)
+		n(w, r)
+	})
+
+	tests := []struct {
+		method  string
+		host    string
+		path    string
+		kind    MatchKind
+		route   *Route
+		pattern string
+	}{
+		{method: http.MethodGet, path: "/users/andrew", kind: MatchRoute, route: users, pattern: "/users/:id"},
+		{method: http.MethodHead, path: "/users/andrew", kind: MatchRoute, route: users, pattern: "/users/:id"},
+		{method: http.MethodPost, path: "/users/andrew", kind: MatchMethodNotAllowed, route: users, pattern: "/users/:id"},
+		{method: http.MethodOptions, path: "/users/andrew", kind: MatchOptions, route: users, pattern: "/users/:id"},
+		{method: http.MethodOptions, path: "/docs/missing", kind: MatchOptions},
+		{method: http.MethodGet, path: "/missing", kind: MatchNotFound},
+		{method: http.MethodGet, path: "/users//andrew", kind: MatchBadRequest},
+		{method: http.MethodGet, host: "example.com", path: "/", kind: MatchRoute, pattern: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.host+tt.path, func(t *testing.T) {
+			match = Match{}
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.URL.Host = tt.host
+			s.ServeHTTP(httptest.NewRecorder(), req)
+
+			if match.Kind != tt.kind {
+				t.Errorf("Wrong match kind, expected=%s actual=%s", tt.kind, match.Kind)
+			}
+			if tt.route != nil && match.Route != tt.route {
+				t.Error("Wrong route matched")
+			}
+			if match.Pattern != tt.pattern {
+				t.Errorf("Wrong pattern, expected=%s actual=%s", tt.pattern, match.Pattern)
+			}
+			if match.Host != tt.host {
+				t.Errorf("Wrong host, expected=%s actual=%s", tt.host, match.Host)
+			}
+		})
+	}
+}
+
+func TestMatchInfo_Redirect(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(wrongHandler)
+	s.Route("/users").Get(rightHandler)
+
+	// trailing slashes are redirected before any middleware runs
+	req := httptest.NewRequest(http.MethodGet, "/users/", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPermanentRedirect || rec.Body.String() == string(wrongHandler) {
+		t.Error("Wrong redirect", rec.Code, rec.Body.String())
+	}
+
+	trace := s.Explain(req)
+	if trace.Match.Kind != MatchRedirect || trace.Match.Pattern != "/users" || len(trace.Middleware) != 0 {
+		t.Error("Wrong match", trace.Match, trace.Middleware)
+	}
+}
@@ -0,0 +1,106 @@
+package powermux
+
+import "net/http"
+
+// Meta attaches a metadata value to this route and every route below it.
+// Routes further down the tree may override the value by setting the same key.
+func (r *Route) Meta(key string, value interface{}) *Route {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = value
+	return r
+}
+
+// MetaFor attaches a metadata value to this route and every route below it, but only for requests
+// with the verb specified. Values set for a specific verb take precedence over those set with Meta
+// on the same route.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if the verb is unknown.
+func (r *Route) MetaFor(verb, key string, value interface{}) *Route {
+	// validate the verb
+	getVerbFlagForMethod(verb)
+
+	if r.methodMeta == nil {
+		r.methodMeta = make(map[string]map[string]interface{})
+	}
+	if r.methodMeta[verb] == nil {
+		r.methodMeta[verb] = make(map[string]interface{})
+	}
+	r.methodMeta[verb][key] = value
+	return r
+}
+
+// localMeta returns the value set on this node alone for the method and key.
+// HEAD requests fall back on values set for GET, as they are served by the same handler.
+func (r *Route) localMeta(method, key string) (interface{}, bool) {
+	if v, ok := r.methodMeta[method][key]; ok {
+		return v, true
+	}
+	if method == http.MethodHead {
+		if v, ok := r.methodMeta[http.MethodGet][key]; ok {
+			return v, true
+		}
+	}
+	v, ok := r.meta[key]
+	return v, ok
+}
+
+// LookupMeta returns the metadata value for the method and key that applies to this route,
+// searching up the tree from this route.
+func (r *Route) LookupMeta(method, key string) (interface{}, bool) {
+	for cur := r; cur != nil; cur = cur.parent {
+		if v, ok := cur.localMeta(method, key); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// mergedMeta returns all the metadata that applies to this route for the method
+func (r *Route) mergedMeta(method string) map[string]interface{} {
+	// collect the nodes from the root down
+	nodes := make([]*Route, 0, 8)
+	for cur := r; cur != nil; cur = cur.parent {
+		nodes = append(nodes, cur)
+	}
+
+	merged := make(map[string]interface{})
+	for i := len(nodes) - 1; i >= 0; i-- {
+		for k, v := range nodes[i].meta {
+			merged[k] = v
+		}
+		if method == http.MethodHead {
+			for k, v := range nodes[i].methodMeta[http.MethodGet] {
+				merged[k] = v
+			}
+		}
+		for k, v := range nodes[i].methodMeta[method] {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// RouteMeta returns the metadata value for the key that applies to the route serving the request.
+//
+// Requests that did not match a route use the metadata of the deepest route their path reached,
+// so metadata set on a section of routes applies to not found responses within it.
+func RouteMeta(req *http.Request, key string) (value interface{}, ok bool) {
+	ex := getRequestExecution(req)
+	if ex.node == nil {
+		return nil, false
+	}
+	return ex.node.LookupMeta(ex.method, key)
+}
+
+// RouteMetadata returns all the metadata that applies to the route serving the request.
+//
+// Altering the values of this map will not affect the route or future calls to RouteMetadata.
+func RouteMetadata(req *http.Request) map[string]interface{} {
+	ex := getRequestExecution(req)
+	if ex.node == nil {
+		return make(map[string]interface{})
+	}
+	return ex.node.mergedMeta(ex.method)
+}
@@ -0,0 +1,70 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteMeta(t *testing.T) {
+	s := NewServeMux()
+
+	var team, tier interface{}
+	var merged map[string]interface{}
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		team, _ = RouteMeta(r, "team")
+		tier, _ = RouteMeta(r, "tier")
+		merged = RouteMetadata(r)
+		n(w, r)
+	})
+
+	s.Route("/api").Meta("team", "platform").Meta("tier", "standard")
+	s.Route("/api/search").
+		MetaFor(http.MethodPost, "tier", "expensive").
+		Get(rightHandler).
+		Post(rightHandler)
+	s.Route("/api/users").Meta("team", "identity").Get(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		team   interface{}
+		tier   interface{}
+	}{
+		{method: http.MethodGet, path: "/api/search", team: "platform", tier: "standard"},
+		{method: http.MethodPost, path: "/api/search", team: "platform", tier: "expensive"},
+		{method: http.MethodGet, path: "/api/users", team: "identity", tier: "standard"},
+		{method: http.MethodGet, path: "/api/missing", team: "platform", tier: "standard"},
+		{method: http.MethodGet, path: "/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			s.ServeHTTP(httptest.NewRecorder(), req)
+
+			if team != tt.team || tier != tt.tier {
+				t.Errorf("Wrong metadata, team=%v tier=%v", team, tier)
+			}
+
+			if merged["team"] != tt.team || merged["tier"] != tt.tier {
+				t.Errorf("Wrong merged metadata %v", merged)
+			}
+		})
+	}
+}
+
+func TestRoute_LookupMeta(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/a").MetaFor(http.MethodGet, "summary", "get a")
+	b := s.Route("/a/b")
+
+	if v, ok := b.LookupMeta(http.MethodHead, "summary"); !ok || v != "get a" {
+		t.Error("HEAD should inherit GET metadata", v)
+	}
+
+	if _, ok := b.LookupMeta(http.MethodPost, "summary"); ok {
+		t.Error("POST should not see GET metadata")
+	}
+}
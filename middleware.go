@@ -2,6 +2,7 @@ package powermux
 
 import (
 	"net/http"
+	"reflect"
 )
 
 //The MiddlewareFunc type is an adapter to allow the use of ordinary functions as HTTP middlewares.
@@ -19,6 +20,33 @@ type Middleware interface {
 	ServeHTTPMiddleware(http.ResponseWriter, *http.Request, func(http.ResponseWriter, *http.Request))
 }
 
+// adaptedMiddleware is a standard func(http.Handler) http.Handler middleware used as a Middleware
+type adaptedMiddleware func(http.Handler) http.Handler
+
+// ServeHTTPMiddleware wraps the next function in the standard middleware and serves the request with the result.
+func (m adaptedMiddleware) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, n func(http.ResponseWriter, *http.Request)) {
+	m(http.HandlerFunc(n)).ServeHTTP(rw, req)
+}
+
+// Adapt allows the use of standard func(http.Handler) http.Handler middleware anywhere a Middleware is expected.
+func Adapt(m func(http.Handler) http.Handler) Middleware {
+	return adaptedMiddleware(m)
+}
+
+// Wrapper returns a standard func(http.Handler) http.Handler middleware that runs m before the handler it wraps.
+// Middleware created with Adapt is returned as it was originally.
+func Wrapper(m Middleware) func(http.Handler) http.Handler {
+	if a, ok := m.(adaptedMiddleware); ok {
+		return a
+	}
+	return func(next http.Handler) http.Handler {
+		n := next.ServeHTTP
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			m.ServeHTTPMiddleware(rw, req, n)
+		})
+	}
+}
+
 // getNextMiddleware returns the first middleware of a recursive closure.
 // The returned middleware will have the next middleware in the array available to it as a parameter
 // and the last middleware will have the final handler.
@@ -31,3 +59,63 @@ func getNextMiddleware(mids []Middleware, h http.Handler) func(http.ResponseWrit
 		}
 	}
 }
+
+// taggedMiddleware is a middleware with tags that can be used to exclude it from parts of the route tree
+type taggedMiddleware struct {
+	Middleware
+	tags []string
+}
+
+// Tag labels a middleware with tags, so that it can be excluded from parts of the route tree with
+// Route.Without.
+func Tag(m Middleware, tags ...string) Middleware {
+	return &taggedMiddleware{
+		Middleware: m,
+		tags:       tags,
+	}
+}
+
+// hasTag reports if the middleware was labelled with the tag
+func hasTag(m Middleware, tag string) bool {
+	for {
+		t, ok := m.(*taggedMiddleware)
+		if !ok {
+			return false
+		}
+		for _, mt := range t.tags {
+			if mt == tag {
+				return true
+			}
+		}
+		m = t.Middleware
+	}
+}
+
+// untag returns the middleware without any tags applied
+func untag(m Middleware) Middleware {
+	for {
+		t, ok := m.(*taggedMiddleware)
+		if !ok {
+			return m
+		}
+		m = t.Middleware
+	}
+}
+
+// sameMiddleware reports if two middleware are the same, ignoring tags.
+// Middleware that can't be identified is never the same as anything.
+func sameMiddleware(a, b Middleware) bool {
+	a, b = untag(a), untag(b)
+	if !identifiable(a) || reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	return a == b
+}
+
+// identifiable reports if the middleware can be told apart from others by equality, ignoring tags.
+// Functions can't, as every method value of a method and every closure from the same function share
+// their code, whatever they were created with.
+func identifiable(m Middleware) bool {
+	t := reflect.TypeOf(untag(m))
+	return t != nil && t.Kind() != reflect.Func && t.Comparable()
+}
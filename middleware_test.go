@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -46,3 +47,75 @@ func TestMiddleware_getNextMiddleware(t *testing.T) {
 		t.Log(recorder.Body.String())
 	}
 }
+
+func stdMiddleware(s string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, s)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRoute_Use(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		Middleware(mid1).
+		Use(stdMiddleware("std1")).
+		Middleware(mid2).
+		UseFor(stdMiddleware("std2"), http.MethodPost).
+		Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "mid1std1mid2right" {
+		t.Error("Middlewares not executed in order", rec.Body.String())
+	}
+}
+
+func TestWrapper(t *testing.T) {
+	h := Wrapper(mid1)(rightHandler)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Body.String() != "mid1right" {
+		t.Error("Middleware not executed", rec.Body.String())
+	}
+
+	// adapted middleware should come back unchanged
+	std := stdMiddleware("std")
+	if reflect.ValueOf(Wrapper(Adapt(std))).Pointer() != reflect.ValueOf(std).Pointer() {
+		t.Error("Adapted middleware was wrapped again")
+	}
+}
+
+func TestAdapt_Allocations(t *testing.T) {
+	passthrough := func(next http.Handler) http.Handler {
+		return next
+	}
+
+	noop := MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		n(w, r)
+	})
+
+	native := []Middleware{noop, noop, noop}
+	adapted := []Middleware{Adapt(passthrough), Adapt(passthrough), Adapt(passthrough)}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	nativeAllocs := testing.AllocsPerRun(100, func() {
+		getNextMiddleware(native, emptyHandle)(rec, req)
+	})
+	adaptedAllocs := testing.AllocsPerRun(100, func() {
+		getNextMiddleware(adapted, emptyHandle)(rec, req)
+	})
+
+	if adaptedAllocs > nativeAllocs {
+		t.Errorf("Adapted middleware allocates more than native, adapted=%f native=%f", adaptedAllocs, nativeAllocs)
+	}
+}
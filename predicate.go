@@ -0,0 +1,144 @@
+package powermux
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// A Predicate decides if conditional middleware is executed for a request.
+// It is given the request and how the request was routed.
+type Predicate func(req *http.Request, match Match) bool
+
+// Not holds when p does not.
+func Not(p Predicate) Predicate {
+	return func(req *http.Request, match Match) bool {
+		return !p(req, match)
+	}
+}
+
+// And holds when all of the predicates hold.
+func And(ps ...Predicate) Predicate {
+	return func(req *http.Request, match Match) bool {
+		for _, p := range ps {
+			if !p(req, match) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or holds when any of the predicates hold.
+func Or(ps ...Predicate) Predicate {
+	return func(req *http.Request, match Match) bool {
+		for _, p := range ps {
+			if p(req, match) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IfHost holds when the request's host, without any port, matches one of the patterns.
+// Patterns are either exact host names, or start with "*." to match any subdomain.
+func IfHost(patterns ...string) Predicate {
+	return func(req *http.Request, _ Match) bool {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(pattern)
+			if strings.HasPrefix(pattern, "*.") {
+				if strings.HasSuffix(host, pattern[1:]) {
+					return true
+				}
+			} else if host == pattern {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IfHeader holds when the request has the header. If value is not empty, the header must also have that value.
+func IfHeader(name, value string) Predicate {
+	return func(req *http.Request, _ Match) bool {
+		values, ok := req.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IfContentType holds when the request's media type, ignoring any parameters, is one of the types given.
+func IfContentType(types ...string) Predicate {
+	return func(req *http.Request, _ Match) bool {
+		mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, t := range types {
+			if strings.EqualFold(mediaType, t) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IfClientIP holds when the request's remote address is in one of the CIDR ranges given.
+// Panics if any of the ranges can't be parsed.
+func IfClientIP(cidrs ...string) Predicate {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic("powermux: IfClientIP: " + err.Error())
+		}
+		nets = append(nets, n)
+	}
+
+	return func(req *http.Request, _ Match) bool {
+		host := req.RemoteAddr
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IfMatch holds when the request was routed with one of the outcomes given.
+func IfMatch(kinds ...MatchKind) Predicate {
+	return func(_ *http.Request, match Match) bool {
+		for _, k := range kinds {
+			if match.Kind == k {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute_MiddlewareIf(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		MiddlewareIf(IfMatch(MatchRoute), mid1).
+		MiddlewareIf(And(IfHeader("X-Debug", ""), Not(IfClientIP("10.0.0.0/8"))), mid2)
+	s.Route("/a").Get(rightHandler)
+
+	tests := []struct {
+		path   string
+		debug  bool
+		remote string
+		body   string
+	}{
+		{path: "/a", body: "mid1right"},
+		{path: "/a", debug: true, body: "mid1mid2right"},
+		{path: "/a", debug: true, remote: "10.1.2.3:1234", body: "mid1right"},
+		{path: "/missing", debug: true, body: "mid2404 page not found\n"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.debug {
+			req.Header.Set("X-Debug", "1")
+		}
+		if tt.remote != "" {
+			req.RemoteAddr = tt.remote
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != tt.body {
+			t.Errorf("Wrong execution for %s, expected=%q actual=%q", tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Host = "api.example.com:8080"
+	req.RemoteAddr = "192.168.1.20:5000"
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Tenant", "acme")
+
+	match := Match{Kind: MatchNotFound}
+
+	tests := []struct {
+		name   string
+		p      Predicate
+		expect bool
+	}{
+		{name: "host subdomain", p: IfHost("*.example.com"), expect: true},
+		{name: "host exact", p: IfHost("API.example.com"), expect: true},
+		{name: "host other", p: IfHost("example.com"), expect: false},
+		{name: "header value", p: IfHeader("x-tenant", "acme"), expect: true},
+		{name: "header wrong value", p: IfHeader("X-Tenant", "other"), expect: false},
+		{name: "header missing", p: IfHeader("X-Missing", ""), expect: false},
+		{name: "content type", p: IfContentType("text/plain", "application/json"), expect: true},
+		{name: "content type other", p: IfContentType("text/plain"), expect: false},
+		{name: "client ip", p: IfClientIP("10.0.0.0/8", "192.168.0.0/16"), expect: true},
+		{name: "client ip other", p: IfClientIP("10.0.0.0/8"), expect: false},
+		{name: "match", p: IfMatch(MatchRoute, MatchNotFound), expect: true},
+		{name: "match other", p: IfMatch(MatchRoute), expect: false},
+		{name: "or", p: Or(IfMatch(MatchRoute), IfHost("*.example.com")), expect: true},
+		{name: "and", p: And(IfMatch(MatchRoute), IfHost("*.example.com")), expect: false},
+	}
+
+	for _, tt := range tests {
+		if tt.p(req, match) != tt.expect {
+			t.Errorf("Predicate %s should have been %t", tt.name, tt.expect)
+		}
+	}
+}
+
+func TestIfClientIP_Panic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Did not panic")
+		}
+	}()
+
+	IfClientIP("not a range")
+}
@@ -0,0 +1,127 @@
+package powermux
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object describing an error response.
+type Problem struct {
+	// Type is a URI reference identifying the problem type, "about:blank" by default
+	Type string
+	// Title is a short summary of the problem type
+	Title string
+	// Status is the HTTP status code of the response
+	Status int
+	// Detail is an explanation specific to this occurrence of the problem
+	Detail string
+	// Instance is a URI reference identifying this occurrence of the problem
+	Instance string
+	// Extensions are additional members added to the problem object
+	Extensions map[string]interface{}
+}
+
+// newProblem creates a problem for the status code and request with the standard fields filled
+func newProblem(r *http.Request, status int) *Problem {
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.RequestURI(),
+	}
+}
+
+// MarshalJSON encodes the problem as a single object with the extensions alongside the standard members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		obj[k] = v
+	}
+	obj["type"] = p.Type
+	obj["title"] = p.Title
+	obj["status"] = p.Status
+	if p.Detail != "" {
+		obj["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		obj["instance"] = p.Instance
+	}
+	return json.Marshal(obj)
+}
+
+// ProblemRenderer writes a problem to the response.
+type ProblemRenderer interface {
+	RenderProblem(http.ResponseWriter, *http.Request, *Problem)
+}
+
+// The ProblemRendererFunc type is an adapter to allow the use of ordinary functions as problem renderers.
+type ProblemRendererFunc func(http.ResponseWriter, *http.Request, *Problem)
+
+// RenderProblem calls f(w, r, p).
+func (f ProblemRendererFunc) RenderProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	f(w, r, p)
+}
+
+// ProblemJSON renders problems as "application/problem+json" documents.
+var ProblemJSON ProblemRenderer = ProblemRendererFunc(renderProblemJSON)
+
+func renderProblemJSON(w http.ResponseWriter, _ *http.Request, p *Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// problemHandler is implemented by the handlers powermux generates itself,
+// so they can be rendered as problems instead of their plain responses.
+type problemHandler interface {
+	http.Handler
+	// problem sets any headers the response needs and describes it as a problem
+	problem(http.ResponseWriter, *http.Request, *routeExecution) *Problem
+}
+
+// problemResponse renders a generated handler's response with a ProblemRenderer.
+type problemResponse struct {
+	ex       *routeExecution
+	gen      problemHandler
+	renderer ProblemRenderer
+}
+
+// ServeHTTP renders the generated handler's problem
+func (p *problemResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.renderer.RenderProblem(w, r, p.gen.problem(w, r, p.ex))
+}
+
+// Problems renders every response powermux generates itself, such as not found, method not allowed,
+// bad request and trailing slash redirect responses, as problems using the renderer.
+// Handlers registered on routes are unaffected.
+//
+// Use ProblemJSON for RFC 7807 "application/problem+json" responses.
+// Passing nil restores the plain responses.
+func (s *ServeMux) Problems(renderer ProblemRenderer) {
+	s.problems = renderer
+}
+
+// ProblemsHost sets the renderer used for generated responses to requests for a specific host,
+// overriding the renderer set with Problems.
+// Passing nil restores the renderer set with Problems for the host.
+func (s *ServeMux) ProblemsHost(host string, renderer ProblemRenderer) {
+	if renderer == nil {
+		delete(s.hostProblems, host)
+		return
+	}
+	s.hostProblems[host] = renderer
+}
+
+// problemRenderer returns the renderer to use for a host, or nil if problems are disabled
+func (s *ServeMux) problemRenderer(host string) ProblemRenderer {
+	if renderer, ok := s.hostProblems[host]; ok {
+		return renderer
+	}
+	return s.problems
+}
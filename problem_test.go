@@ -0,0 +1,129 @@
+package powermux
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveProblem(t *testing.T, s *ServeMux, req *http.Request) (*httptest.ResponseRecorder, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatal("Wrong content type", ct)
+	}
+
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal("Invalid problem body", err)
+	}
+
+	if int(body["status"].(float64)) != rec.Code {
+		t.Error("Problem status does not match response", body["status"], rec.Code)
+	}
+
+	return rec, body
+}
+
+func TestProblems_NotFound(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+
+	rec, body := serveProblem(t, s, httptest.NewRequest(http.MethodGet, "/missing?q=1", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if body["type"] != "about:blank" || body["title"] != "Not Found" || body["instance"] != "/missing?q=1" {
+		t.Error("Wrong problem", body)
+	}
+}
+
+func TestProblems_MethodNotAllowed(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+	s.Route("/a").Get(wrongHandler).Post(wrongHandler)
+
+	rec, body := serveProblem(t, s, httptest.NewRequest(http.MethodDelete, "/a", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if rec.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		t.Error("Wrong Allow header", rec.Header().Get("Allow"))
+	}
+
+	if allowed, ok := body["allowed"].([]interface{}); !ok || len(allowed) != 4 {
+		t.Error("Wrong allowed methods", body["allowed"])
+	}
+}
+
+func TestProblems_BadRequest(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+
+	rec, body := serveProblem(t, s, httptest.NewRequest(http.MethodGet, "/a//b", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if body["reason"] != ErrDoubleSlash.String() {
+		t.Error("Wrong reason", body["reason"])
+	}
+}
+
+func TestProblems_Redirect(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+
+	rec, body := serveProblem(t, s, httptest.NewRequest(http.MethodGet, "/a/", nil))
+
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if rec.Header().Get("Location") != "/a" || body["location"] != "/a" {
+		t.Error("Wrong redirect location", rec.Header().Get("Location"), body["location"])
+	}
+}
+
+func TestProblems_RegisteredHandlers(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+	s.NotFound(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "right" {
+		t.Error("Registered handler was replaced")
+	}
+}
+
+func TestProblems_Host(t *testing.T) {
+	s := NewServeMux()
+	s.Problems(ProblemJSON)
+	s.ProblemsHost("example.com", ProblemRendererFunc(func(w http.ResponseWriter, r *http.Request, p *Problem) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(p.Status)
+		io.WriteString(w, "<h1>"+p.Title+"</h1>")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.URL.Host = "example.com"
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "<h1>Not Found</h1>" {
+		t.Error("Host renderer not used", rec.Code, rec.Body.String())
+	}
+
+	serveProblem(t, s, httptest.NewRequest(http.MethodGet, "/missing", nil))
+}
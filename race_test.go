@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package powermux
+
+// raceEnabled is set when the race detector is on, which makes pooling and allocation counts unreliable
+const raceEnabled = true
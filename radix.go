@@ -0,0 +1,137 @@
+package powermux
+
+// radixNode is a node of the compressed radix tree a route uses to find the literal routes below it.
+//
+// The root and every path parameter anchor a tree, keyed by the path from the anchor to each literal
+// route below it, up to the next path parameter or wildcard. A chain of literals like /api/v1/users
+// is a single edge, and patterns that share a prefix share the edges for it, so the literal part of a
+// path is matched byte by byte, across segments, comparing each byte once no matter how many
+// routes there are.
+//
+// Matching reports the route reached at the end of every segment, so routing still visits each of
+// them in order to collect their middleware, requirements and handlers.
+type radixNode struct {
+	// the part of the pattern this edge matches
+	prefix string
+	// the first byte of each edge below this one, in the same order as edges
+	indices string
+	edges   []*radixNode
+	// the child whose pattern ends at this edge, if any
+	route *Route
+}
+
+// lookup returns the route whose key is exactly the path, or nil if there isn't one
+func (n *radixNode) lookup(path string) *Route {
+	for {
+		if len(path) < len(n.prefix) {
+			return nil
+		}
+		for i := 0; i < len(n.prefix); i++ {
+			if path[i] != n.prefix[i] {
+				return nil
+			}
+		}
+		path = path[len(n.prefix):]
+
+		if path == "" {
+			return n.route
+		}
+
+		next := n.edge(path[0])
+		if next == nil {
+			return nil
+		}
+		n = next
+	}
+}
+
+// match follows the path through the tree byte by byte, across segments, for as long as each segment
+// ends at a route, appending the route reached by each to the run
+func (n *radixNode) match(path string, run []*Route) []*Route {
+	// the number of bytes of the current edge matched
+	i := 0
+	for j := 0; j < len(path); j++ {
+		c := path[j]
+
+		// the segment has to be a whole pattern, not just the start of one
+		if c == '/' {
+			if i < len(n.prefix) || n.route == nil {
+				return run
+			}
+			run = append(run, n.route)
+		}
+
+		if i == len(n.prefix) {
+			if n = n.edge(c); n == nil {
+				return run
+			}
+			i = 0
+		}
+		if n.prefix[i] != c {
+			return run
+		}
+		i++
+	}
+
+	if i == len(n.prefix) && n.route != nil {
+		run = append(run, n.route)
+	}
+	return run
+}
+
+// edge returns the edge below this one that starts with the byte, or nil if there isn't one
+func (n *radixNode) edge(c byte) *radixNode {
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == c {
+			return n.edges[i]
+		}
+	}
+	return nil
+}
+
+// insert adds a route to the tree under its key, splitting edges where the pattern diverges
+func (n *radixNode) insert(pattern string, route *Route) {
+	for {
+		common := commonPrefix(pattern, n.prefix)
+
+		// split this edge where the pattern leaves it
+		if common < len(n.prefix) {
+			split := &radixNode{
+				prefix:  n.prefix[common:],
+				indices: n.indices,
+				edges:   n.edges,
+				route:   n.route,
+			}
+			n.prefix = n.prefix[:common]
+			n.indices = split.prefix[:1]
+			n.edges = []*radixNode{split}
+			n.route = nil
+		}
+		pattern = pattern[common:]
+
+		if pattern == "" {
+			n.route = route
+			return
+		}
+
+		next := n.edge(pattern[0])
+		if next == nil {
+			n.indices += pattern[:1]
+			n.edges = append(n.edges, &radixNode{
+				prefix: pattern,
+				route:  route,
+			})
+			return
+		}
+		n = next
+	}
+}
+
+// commonPrefix returns the length of the prefix the strings share
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
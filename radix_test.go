@@ -0,0 +1,167 @@
+package powermux
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRadix_Lookup(t *testing.T) {
+	patterns := []string{"users", "user", "us", "usage", "teams", "team", "t", "u", "admin"}
+
+	root := radixNode{}
+	routes := make(map[string]*Route)
+	for _, pattern := range patterns {
+		routes[pattern] = &Route{pattern: pattern}
+		root.insert(pattern, routes[pattern])
+	}
+
+	for _, pattern := range patterns {
+		if got := root.lookup(pattern); got != routes[pattern] {
+			t.Error("Wrong route for", pattern, got)
+		}
+	}
+
+	for _, missing := range []string{"", "use", "userss", "tea", "adm", "x", "usersx", "admins"} {
+		if got := root.lookup(missing); got != nil {
+			t.Error("Route found for", missing, got.pattern)
+		}
+	}
+}
+
+func TestRadix_Replace(t *testing.T) {
+	root := radixNode{}
+	first, second := &Route{}, &Route{}
+
+	root.insert("a", first)
+	root.insert("a", second)
+
+	if root.lookup("a") != second {
+		t.Error("Pattern not replaced")
+	}
+}
+
+func TestRadix_Wide(t *testing.T) {
+	root := radixNode{}
+	routes := make(map[string]*Route)
+	for i := 0; i < MaxWidth; i++ {
+		pattern := hex.EncodeToString([]byte(fmt.Sprint(i)))
+		routes[pattern] = &Route{pattern: pattern}
+		root.insert(pattern, routes[pattern])
+	}
+
+	for pattern, route := range routes {
+		if root.lookup(pattern) != route {
+			t.Fatal("Wrong route for", pattern)
+		}
+		if root.lookup(pattern[:len(pattern)-1]) != routes[pattern[:len(pattern)-1]] {
+			t.Fatal("Wrong route for prefix of", pattern)
+		}
+	}
+}
+
+func TestRadix_Match(t *testing.T) {
+	root := radixNode{}
+	routes := make(map[string]*Route)
+	for _, key := range []string{"api", "api/v1", "api/v1/users", "api/v1/usage", "apis"} {
+		routes[key] = &Route{pattern: key}
+		root.insert(key, routes[key])
+	}
+
+	tests := []struct {
+		segments []string
+		want     []string
+	}{
+		{[]string{"api", "v1", "users", "42"}, []string{"api", "api/v1", "api/v1/users"}},
+		{[]string{"api", "v1", "usage"}, []string{"api", "api/v1", "api/v1/usage"}},
+		{[]string{"api", "v1", "use"}, []string{"api", "api/v1"}},
+		{[]string{"api", "v1x"}, []string{"api"}},
+		{[]string{"apis", "v1"}, []string{"apis"}},
+		{[]string{"apiv1"}, nil},
+		{[]string{""}, nil},
+	}
+
+	for _, test := range tests {
+		run := root.match(strings.Join(test.segments, "/"), nil)
+		if len(run) != len(test.want) {
+			t.Error("Wrong routes for", test.segments, len(run))
+			continue
+		}
+		for i, key := range test.want {
+			if run[i] != routes[key] {
+				t.Error("Wrong route for", test.segments, "at", i, run[i].pattern)
+			}
+		}
+	}
+}
+
+func TestRoute_LiteralAnchors(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api/v1/users").Get(rightHandler)
+	s.Route("/api/v1/users/:id/posts").Get(rightHandler)
+
+	if s.Route("/api/v1/users/:id").literals.lookup("posts") != s.Route("/api/v1/users/:id/posts") {
+		t.Error("Literal not indexed by the parameter above it")
+	}
+	if s.Route("/").literals.lookup("api/v1/users") != s.Route("/api/v1/users") {
+		t.Error("Literal chain not indexed by the root")
+	}
+	if len(s.Route("/api").literals.edges) != 0 {
+		t.Error("Literal indexed by a literal")
+	}
+}
+
+func TestRoute_LiteralChain(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api").Middleware(dummyHandler("api"))
+	s.Route("/api/v1").Middleware(dummyHandler("v1"))
+	s.Route("/api/v1/users").Middleware(dummyHandler("users")).GetFunc(dummyHandlerFunc("list"))
+	s.Route("/api/v1/users/all").GetFunc(dummyHandlerFunc("all"))
+	s.Route("/api/v1/users/:id").GetFunc(dummyHandlerFunc("user"))
+	s.Route("/api/v1/:kind/all").GetFunc(dummyHandlerFunc("kind"))
+	s.Route("/api/*").GetFunc(dummyHandlerFunc("rest"))
+
+	tests := map[string]string{
+		"/api/v1/users":     "apiv1userslist",
+		"/api/v1/users/all": "apiv1usersall",
+		"/api/v1/users/al":  "apiv1usersuser",
+		"/api/v1/teams/all": "apiv1kind",
+		"/api/v1/user/all":  "apiv1kind",
+		"/api/v2/users":     "apirest",
+	}
+
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Body.String() != want {
+			t.Error("Wrong response for", path, rec.Body.String())
+		}
+	}
+}
+
+func TestRoute_LiteralPrecedence(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/user").GetFunc(dummyHandlerFunc("user"))
+	s.Route("/users").GetFunc(dummyHandlerFunc("users"))
+	s.Route("/users/all").GetFunc(dummyHandlerFunc("all"))
+	s.Route("/:name").GetFunc(dummyHandlerFunc("param"))
+
+	tests := map[string]string{
+		"/user":      "user",
+		"/users":     "users",
+		"/users/all": "all",
+		"/use":       "param",
+		"/userss":    "param",
+	}
+
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Body.String() != want {
+			t.Error("Wrong handler for", path, rec.Body.String())
+		}
+	}
+}
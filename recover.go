@@ -0,0 +1,139 @@
+package powermux
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+// Panic describes a panic recovered while serving a request.
+type Panic struct {
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+	// Pattern is the route pattern that matched the request
+	Pattern string
+	// Params are the path parameters of the request
+	Params map[string]string
+}
+
+// PanicReporter is told about panics recovered by a ServeMux.
+type PanicReporter interface {
+	ReportPanic(req *http.Request, p *Panic)
+}
+
+// The PanicReporterFunc type is an adapter to allow the use of ordinary functions as PanicReporters.
+type PanicReporterFunc func(*http.Request, *Panic)
+
+// ReportPanic calls f(req, p).
+func (f PanicReporterFunc) ReportPanic(req *http.Request, p *Panic) {
+	f(req, p)
+}
+
+// Recover enables recovering from panics in middleware and handlers, and in predicates and authorizers while
+// the request is routed.
+// Recovered panics are passed to the reporter, if it isn't nil, and are answered with
+// http.StatusInternalServerError unless the response has already been started.
+//
+// Panics with http.ErrAbortHandler are not recovered, so the server still aborts the response.
+func (s *ServeMux) Recover(reporter PanicReporter) {
+	s.recovering = true
+	s.panicReporter = reporter
+}
+
+// recoverPanic recovers a panic from serving the request, reports it and answers it if it can.
+// It must be deferred directly so that it can recover.
+func (s *ServeMux) recoverPanic(ex *routeExecution, req *http.Request) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	// the server quietly aborts the response for this panic
+	if value == http.ErrAbortHandler {
+		panic(value)
+	}
+
+	// report the request the handlers were served, if it got that far
+	if ex.req.URL != nil {
+		req = &ex.req
+	}
+
+	if s.panicReporter != nil {
+		params := make(map[string]string, len(ex.params))
+		for _, p := range ex.params {
+			params[p.name] = p.value
+		}
+		s.panicReporter.ReportPanic(req, &Panic{
+			Value:   value,
+			Stack:   debug.Stack(),
+			Pattern: ex.pattern,
+			Params:  params,
+		})
+	}
+
+	if ex.writer.started {
+		return
+	}
+
+	w := ex.writer.ResponseWriter
+	if ex.renderer != nil {
+		ex.renderer.RenderProblem(w, req, newProblem(req, http.StatusInternalServerError))
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// recoveryWriter tracks if the response has been started, so a recovered panic knows if it can still answer
+type recoveryWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// WriteHeader starts the response with anything other than an informational status
+func (w *recoveryWriter) WriteHeader(code int) {
+	if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+		w.started = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write starts the response
+func (w *recoveryWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *recoveryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush starts the response and flushes it, if the underlying writer can
+func (w *recoveryWriter) Flush() {
+	w.started = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection, if the underlying writer can
+func (w *recoveryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.started = true
+	return h.Hijack()
+}
+
+// Push pushes the resource, if the underlying writer can
+func (w *recoveryWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
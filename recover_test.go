@@ -0,0 +1,213 @@
+package powermux
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func TestServeMux_Recover(t *testing.T) {
+	s := NewServeMux()
+
+	var reported *Panic
+	var reportedPath string
+	s.Recover(PanicReporterFunc(func(r *http.Request, p *Panic) {
+		reported = p
+		reportedPath = r.URL.Path
+	}))
+
+	s.Route("/users/:id").GetFunc(panicHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/andrew", nil))
+
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "Internal Server Error\n" {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+	if reported == nil {
+		t.Fatal("Panic not reported")
+	}
+	if reported.Value != "boom" || reported.Pattern != "/users/:id" || reported.Params["id"] != "andrew" {
+		t.Error("Wrong report", reported.Value, reported.Pattern, reported.Params)
+	}
+	if !bytes.Contains(reported.Stack, []byte("panicHandler")) {
+		t.Error("Stack doesn't include the panic", string(reported.Stack))
+	}
+	if reportedPath != "/users/andrew" {
+		t.Error("Wrong request reported", reportedPath)
+	}
+
+	// the execution is still reused
+	rec = httptest.NewRecorder()
+	s.Route("/ok").Get(rightHandler)
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if rec.Body.String() != "right" {
+		t.Error("Wrong response after panic", rec.Body.String())
+	}
+}
+
+func TestServeMux_RecoverMiddleware(t *testing.T) {
+	s := NewServeMux()
+	s.Recover(nil)
+	s.Problems(ProblemJSON)
+
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		panic("middleware")
+	}).Get(rightHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Error("Wrong response", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestServeMux_RecoverStarted(t *testing.T) {
+	s := NewServeMux()
+	s.Recover(nil)
+
+	s.Route("/written").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("boom")
+	})
+	s.Route("/flushed").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.(http.Flusher).Flush()
+		panic("boom")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/written", http.StatusOK, "partial"},
+		{"/flushed", http.StatusAccepted, ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.status || rec.Body.String() != test.body {
+			t.Error("Wrong response for", test.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestServeMux_RecoverAbort(t *testing.T) {
+	s := NewServeMux()
+	reported := false
+	s.Recover(PanicReporterFunc(func(r *http.Request, p *Panic) {
+		reported = true
+	}))
+	s.Route("/").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("Abort not passed on")
+		}
+		if reported {
+			t.Error("Abort reported")
+		}
+	}()
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestServeMux_RecoverRouting(t *testing.T) {
+	s := NewServeMux()
+
+	var reported *Panic
+	s.Recover(PanicReporterFunc(func(r *http.Request, p *Panic) {
+		reported = p
+	}))
+	s.Route("/").MiddlewareIf(func(r *http.Request, m Match) bool {
+		panic("predicate")
+	}, mid1).Get(rightHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+	if reported == nil || reported.Value != "predicate" {
+		t.Error("Panic while routing not reported", reported)
+	}
+}
+
+func TestServeMux_UnknownMethod(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/dav").Middleware(mid1).MiddlewareFor(wrongHandler, http.MethodGet).MiddlewareExceptFor(mid2, http.MethodGet).
+		Any(rightHandler)
+	s.Route("/get").Get(wrongHandler)
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"PROPFIND", "/dav", http.StatusOK, "mid1mid2right"},
+		{http.MethodTrace, "/get", http.StatusMethodNotAllowed, ""},
+		{"PROPFIND", "/missing", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+
+		if rec.Code != test.status || !strings.HasPrefix(rec.Body.String(), test.body) {
+			t.Error("Wrong response for", test.method, test.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestServeMux_NoRecover(t *testing.T) {
+	s := NewServeMux()
+
+	var ex *routeExecution
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		ex = lookupExecution(r)
+		n(w, r)
+	}).GetFunc(panicHandler)
+
+	func() {
+		defer func() {
+			if recover() != "boom" {
+				t.Error("Panic recovered")
+			}
+		}()
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	// the execution was reset as it was put back in the pool
+	if ex == nil || ex.pattern != "" {
+		t.Error("Execution not returned to the pool")
+	}
+}
+
+func TestServeMux_RecoverAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Allocations aren't reliable with the race detector")
+	}
+
+	s := NewServeMux()
+	s.Recover(nil)
+	s.Route("/users/:id").Get(emptyHandle)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		s.ServeHTTP(nil, req)
+	})
+	if allocs != 0 {
+		t.Error("Serving with recovery allocates", allocs)
+	}
+}
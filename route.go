@@ -1,11 +1,13 @@
 package powermux
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -27,19 +29,7 @@ func (l childList) Swap(i, j int) {
 	l[i], l[j] = l[j], l[i]
 }
 
-func (l childList) Search(pattern string) *Route {
-	index := sort.Search(l.Len(), func(i int) bool {
-		return l[i].pattern >= pattern
-	})
-
-	if index < l.Len() && l[index].pattern == pattern {
-		return l[index]
-	}
-
-	return nil
-}
-
-type verbFlag uint8
+type verbFlag uint16
 
 const (
 	flagGet = verbFlag(1) << iota
@@ -50,6 +40,9 @@ const (
 	flagDelete
 	flagConnect
 	flagOptions
+	// requests with any other method, which are all routed alike and only match middleware and
+	// requirements for all methods
+	flagOther
 	flagAny = ^verbFlag(0)
 )
 
@@ -63,6 +56,15 @@ func (f verbFlag) Matches(v verbFlag) bool {
 }
 
 func getVerbFlagForMethod(method string) verbFlag {
+	f := getVerbFlagForRequest(method)
+	if f == flagOther {
+		panic("powermux: getVerbFlag: not a valid http method: " + method)
+	}
+	return f
+}
+
+// getVerbFlagForRequest returns the flag for the method of a request, which can be any method at all
+func getVerbFlagForRequest(method string) verbFlag {
 	switch method {
 	case http.MethodGet:
 		return flagGet
@@ -81,18 +83,93 @@ func getVerbFlagForMethod(method string) verbFlag {
 	case http.MethodOptions:
 		return flagOptions
 	default:
-		panic("powermux: getVerbFlag: not a valid http method: " + method)
+		return flagOther
+	}
+}
+
+// getVerbFlagForMethods combines the flags for all the methods
+func getVerbFlagForMethods(methods []string) verbFlag {
+	f := verbFlag(0)
+	for _, method := range methods {
+		f = f | getVerbFlagForMethod(method)
 	}
+	return f
 }
 
 type middlewareForVerb struct {
 	mid  Middleware
 	verb verbFlag
+	// if set, the middleware is only executed if this holds
+	cond Predicate
+}
+
+// middlewareSkip excludes inherited middleware, either a specific middleware or all with a tag
+type middlewareSkip struct {
+	mid  Middleware
+	tag  string
+	verb verbFlag
+}
+
+// Excludes reports if the skip excludes the middleware
+func (s *middlewareSkip) Excludes(m Middleware) bool {
+	if s.mid != nil {
+		return sameMiddleware(s.mid, m)
+	}
+	return hasTag(m, s.tag)
+}
+
+// newMiddlewareFor creates a middleware that will only be executed for the verbs specified.
+// Returns nil if it would never be executed.
+func newMiddlewareFor(m Middleware, verbs []string) *middlewareForVerb {
+
+	// Equivalent to none
+	if len(verbs) == 0 {
+		return nil
+	}
+
+	f := getVerbFlagForMethods(verbs)
+
+	// we don't check if this is equivalent to flagAny since a
+	// fully loaded flag set is the same as the flagAny
+
+	return &middlewareForVerb{
+		mid:  m,
+		verb: f,
+	}
+}
+
+// newMiddlewareExceptFor creates a middleware that will only be executed for verbs not in the list.
+// Returns nil if it would never be executed.
+func newMiddlewareExceptFor(m Middleware, verbs []string) *middlewareForVerb {
+
+	// Equivalent to any
+	if len(verbs) == 0 {
+		return &middlewareForVerb{
+			mid:  m,
+			verb: flagAny,
+		}
+	}
+
+	// build the list as if we are calculating For
+	// then invert to get ExceptFor
+	f := ^getVerbFlagForMethods(verbs)
+
+	// Equivalent to none
+	if f == 0 {
+		return nil
+	}
+
+	return &middlewareForVerb{
+		mid:  m,
+		verb: f,
+	}
 }
 
+// pathPartsPool holds pointers to slices, so they keep any growth and are pooled without allocating
 var pathPartsPool = &sync.Pool{
 	New: func() interface{} {
-		return make([]string, 0, 5)
+		parts := make([]string, 0, 5)
+		return &parts
 	},
 }
 
@@ -107,18 +184,59 @@ type Route struct {
 	isParam bool
 	// the name of our path parameter
 	paramName string
+	// the checks applied to the value of our path parameter
+	constraint ParamConstraint
+	parser     ParamParser
 	// if we are a rooted sub tree '/dir/*'
 	isWildcard bool
 	// the array of middleware this node invokes
 	middleware []*middlewareForVerb
-	// child nodes
+	// child nodes, sorted by pattern
 	children childList
+	// the compressed radix tree indexing the literal routes below the root or a path parameter,
+	// by their path from here
+	literals radixNode
 	// child node for path parameters
 	paramChild *Route
 	// set if there's a wildcard handler child (lowest priority)
 	wildcardChild *Route
 	// the map of handlers for different methods
 	handlers map[string]http.Handler
+	// the sorted list of methods with an explicit handler
+	methods []string
+	// the methods advertised in the Allow header of a generated OPTIONS response
+	allowed []string
+	// generated handlers, rebuilt whenever the handlers map changes
+	notAllowedHandler http.Handler
+	optionsHandler    http.Handler
+	// the CORS policy for this node and all below it
+	cors *CORS
+	// the handler for malformed paths on this node and all below it
+	badRequest http.Handler
+	// the handler for errors returned by handlers on this node and all below it
+	errorHandler ErrorHandler
+	// the node above us, nil for the root
+	parent *Route
+	// the version of the routes of the ServeMux this node belongs to
+	version *routesVersion
+	// metadata for this node and all below it
+	meta map[string]interface{}
+	// metadata for specific methods on this node and all below it
+	methodMeta map[string]map[string]interface{}
+	// the requirements requests to this node and all below it must satisfy
+	requirements []*requirementForVerb
+	// the middleware bound to specific handlers, run only when that handler is used
+	endpointMiddleware map[string][]*middlewareForVerb
+	// the middleware of the groups specific handlers were registered through, run only when that handler is used
+	groupMiddleware map[string][]*groupMiddleware
+	// the middleware run only for requests that end at this node
+	localMiddleware []*middlewareForVerb
+	// the inherited middleware excluded from this node and all below it
+	skips []*middlewareSkip
+	// the middleware and handler chains compiled for requests ending here, by verb
+	chains [9]atomic.Value
+	// the request and response types of typed handlers, by method
+	types map[string]handlerTypes
 }
 
 // newRoute allocates all the structures required for a route node.
@@ -128,6 +246,7 @@ func newRoute() *Route {
 		handlers:   make(map[string]http.Handler),
 		middleware: make([]*middlewareForVerb, 0),
 		children:   make([]*Route, 0),
+		version:    &routesVersion{},
 	}
 }
 
@@ -135,13 +254,27 @@ func newRoute() *Route {
 // a route.
 func (r *Route) execute(ex *routeExecution, method, pattern string) {
 
-	if strings.Contains(pattern, "//") {
-		ex.handler = r.badRequest("Invalid path")
+	if i := strings.Index(pattern, "//"); i >= 0 {
+		ex.err = &RoutingError{Kind: ErrDoubleSlash}
+
+		// walk only the well formed prefix to find the bad request handler
+		pattern = pattern[:i]
+		if pattern == "" {
+			pattern = "/"
+		}
+	}
+
+	// redirect trailing slashes
+	if ex.err == nil && pattern != "/" && strings.HasSuffix(pattern, "/") {
+		target := strings.TrimSuffix(pattern, "/")
+		ex.handler = trailingSlashHandler(target)
+		ex.pattern = target
+		ex.kind = MatchRedirect
 		return
 	}
 
-	pathParts := pathPartsPool.Get().([]string)[0:0]
-	defer pathPartsPool.Put(pathParts)
+	pooledParts := pathPartsPool.Get().(*[]string)
+	pathParts := (*pooledParts)[0:0]
 	pathParts = append(pathParts, "")
 	start := 1
 	for i := 1; i < len(pattern); i++ {
@@ -152,38 +285,112 @@ func (r *Route) execute(ex *routeExecution, method, pattern string) {
 		}
 	}
 
-	// redirect trailing slashes
-	if pattern != "/" && strings.HasSuffix(pattern, "/") {
-		target := strings.TrimSuffix(pattern, "/")
-		ex.handler = http.RedirectHandler(target, http.StatusPermanentRedirect)
-		ex.pattern = target
-		return
-	}
-
 	// get the trailing path param
 	if pattern != "/" {
 		pathParts = append(pathParts, pattern[start:])
 	}
 
 	// Fill the execution
-	r.getExecution(method, pathParts, ex)
+	r.getExecution(method, pattern, pathParts, ex)
+
+	*pooledParts = pathParts
+	pathPartsPool.Put(pooledParts)
+
+	// HEAD requests served by the GET handler must also satisfy the requirements for GET
+	if method == http.MethodHead && ex.endpointKey == http.MethodGet {
+		ex.requirements = ex.endpoint.headRequirements(ex.requirements[0:0])
+	}
+
+	// finish with the middleware local to the route the request ended at,
+	// then the middleware bound to the chosen handler
+	if ex.err == nil {
+		verb := getVerbFlagForRequest(method)
+
+		// the middleware of groups the handler was registered through runs where each group was created,
+		// so collect the path again with it in place
+		if ex.endpoint != nil {
+			if groups := ex.endpoint.groupMiddleware[ex.endpointKey]; len(groups) > 0 {
+				ex.middleware = ex.middleware[0:0]
+				ex.conditions = ex.conditions[0:0]
+				ex.node.recollectMiddleware(ex, verb, groups)
+			}
+		}
+
+		if ex.route != nil {
+			for _, mid := range ex.route.localMiddleware {
+				if mid.verb.Matches(verb) {
+					ex.addMiddleware(mid)
+				}
+			}
+		}
+		if ex.endpoint != nil {
+			for _, mid := range ex.endpoint.endpointMiddleware[ex.endpointKey] {
+				if mid.verb.Matches(verb) {
+					ex.addMiddleware(mid)
+				}
+			}
+		}
+	}
+}
+
+// collectMiddleware drops any inherited middleware this node excludes, then saves the middleware of groups
+// created on this node and the node's own middleware, for matching verbs
+func (r *Route) collectMiddleware(ex *routeExecution, verb verbFlag, groups []*groupMiddleware) {
+	for _, skip := range r.skips {
+		if skip.verb.Matches(verb) {
+			ex.skipMiddleware(skip)
+		}
+	}
+
+	for _, g := range groups {
+		if g.anchor == r && g.mid.verb.Matches(verb) {
+			ex.addMiddleware(g.mid)
+		}
+	}
+
+	for i := range r.middleware {
+		if r.middleware[i].verb.Matches(verb) {
+			ex.addMiddleware(r.middleware[i])
+		}
+	}
+}
 
+// recollectMiddleware collects the middleware of every node from the root down to this one,
+// with the middleware of the groups given
+func (r *Route) recollectMiddleware(ex *routeExecution, verb verbFlag, groups []*groupMiddleware) {
+	if r.parent != nil {
+		r.parent.recollectMiddleware(ex, verb, groups)
+	}
+	r.collectMiddleware(ex, verb, groups)
 }
 
 // getExecution is a recursive step in the tree traversal. It checks to see if this node matches,
 // fills out any instructions in the execution, and returns. The return value indicates only if
 // this node matched, not if anything was added to the execution.
-func (r *Route) getExecution(method string, pathParts []string, ex *routeExecution) {
+// The rest of the path is everything after the first of the path parts, starting with its slash.
+func (r *Route) getExecution(method string, rest string, pathParts []string, ex *routeExecution) {
 
 	curRoute := r
-	verb := getVerbFlagForMethod(method)
+	verb := getVerbFlagForRequest(method)
+
+	// the literal routes matched ahead of the walk, and the next of them to visit
+	next := 0
 
 	for {
 
+		// remember how far we got
+		ex.node = curRoute
+		if ex.trace != nil {
+			ex.trace.visit(ex, curRoute, pathParts[0], verb)
+		}
+
 		// save all the middleware for matching verbs
-		for i := range curRoute.middleware {
-			if curRoute.middleware[i].verb.Matches(verb) {
-				ex.middleware = append(ex.middleware, curRoute.middleware[i].mid)
+		curRoute.collectMiddleware(ex, verb, nil)
+
+		// save all the requirements for matching verbs
+		for i := range curRoute.requirements {
+			if curRoute.requirements[i].req != "" && curRoute.requirements[i].verb.Matches(verb) {
+				ex.requirements = append(ex.requirements, curRoute.requirements[i].req)
 			}
 		}
 
@@ -192,24 +399,58 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 			ex.notFound = h
 		}
 
+		// save bad request handler
+		if curRoute.badRequest != nil {
+			ex.badRequest = curRoute.badRequest
+		}
+
+		// save CORS policy
+		if curRoute.cors != nil {
+			ex.cors = curRoute.cors
+		}
+
+		// save error handler
+		if curRoute.errorHandler != nil {
+			ex.errorHandler = curRoute.errorHandler
+		}
+
 		// save options handler
 		if method == http.MethodOptions {
 			if h, ok := curRoute.handlers[http.MethodOptions]; ok {
 				ex.handler = h
+				ex.kind = MatchOptions
+				ex.setEndpoint(curRoute, http.MethodOptions)
 			}
 		}
 
 		// save path parameters
 		if curRoute.isParam {
-			// Errors here will never happen as Go's http server sanitizes inputs before
-			// they are handled by the mux, therefore the error return is ignored
-			value, _ := url.PathUnescape(pathParts[0])
-			ex.params[curRoute.paramName] = value
+			// Go's http server sanitizes inputs before they are handled by the mux,
+			// but requests can be served directly with any URL
+			value, err := url.PathUnescape(pathParts[0])
+			if err != nil {
+				ex.err = &RoutingError{
+					Kind:    ErrInvalidEscape,
+					Segment: pathParts[0],
+					Param:   curRoute.paramName,
+					Err:     err,
+				}
+				return
+			}
+			ex.setParam(curRoute.paramName, value)
+			if !curRoute.checkParam(ex, pathParts[0], value) {
+				return
+			}
 		}
 
 		// check if this is the bottom of the path
 		if len(pathParts) == 1 || curRoute.isWildcard {
 
+			// a malformed path never gets a handler of its own
+			if ex.err != nil {
+				return
+			}
+
 			// hit the bottom of the tree, see if we have a handler to offer
 			curRoute.getHandler(method, ex)
 
@@ -222,28 +463,37 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 
 		}
 
-		// iterate over our children looking for deeper to go
-
-		// binary search over regular children
-		if child := curRoute.children.Search(pathParts[1]); child != nil {
-			pathParts = pathParts[1:]
-			curRoute = child
-			continue
+		// match as much of the rest of the path as possible against the literal routes below,
+		// then visit them one segment at a time
+		if curRoute.isParam || curRoute.parent == nil {
+			ex.literals = curRoute.literals.match(rest[1:], ex.literals[0:0])
+			next = 0
 		}
-
-		// try for params and wildcard children
-		if curRoute.paramChild != nil {
-			pathParts = pathParts[1:]
-			curRoute = curRoute.paramChild
-			continue
+		var literal *Route
+		if next < len(ex.literals) {
+			literal = ex.literals[next]
+			next++
 		}
-		if curRoute.wildcardChild != nil {
-			pathParts = pathParts[1:]
-			curRoute = curRoute.wildcardChild
-			continue
+		if ex.trace != nil {
+			ex.trace.search(curRoute, pathParts[1], literal)
 		}
 
-		return
+		// try for params and wildcard children if there's no literal
+		var child *Route
+		switch {
+		case literal != nil:
+			child = literal
+		case curRoute.paramChild != nil:
+			child = curRoute.paramChild
+		case curRoute.wildcardChild != nil:
+			child = curRoute.wildcardChild
+		default:
+			return
+		}
+
+		rest = rest[1+len(pathParts[1]):]
+		pathParts = pathParts[1:]
+		curRoute = child
 	}
 }
 
@@ -255,9 +505,15 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 // 4. A generated Options handler if this is an options request and no previous handler is set
 // 5. A generated Method Not Allowed response
 func (r *Route) getHandler(method string, ex *routeExecution) {
+	// record what this node supports so handlers can report it
+	ex.route = r
+	ex.allowed = r.allowed
+
 	// check specific method match
 	if h, ok := r.handlers[method]; ok {
 		ex.handler = h
+		ex.kind = MatchRoute
+		ex.setEndpoint(r, method)
 		return
 	}
 
@@ -265,6 +521,8 @@ func (r *Route) getHandler(method string, ex *routeExecution) {
 	if method == http.MethodHead {
 		if h, ok := r.handlers[http.MethodGet]; ok {
 			ex.handler = h
+			ex.kind = MatchRoute
+			ex.setEndpoint(r, http.MethodGet)
 			return
 		}
 	}
@@ -272,18 +530,79 @@ func (r *Route) getHandler(method string, ex *routeExecution) {
 	// check the ANY handler
 	if h, ok := r.handlers[methodAny]; ok {
 		ex.handler = h
+		ex.kind = MatchRoute
+		ex.setEndpoint(r, methodAny)
 		return
 	}
 
-	// last ditch effort is to generate our own method not allowed handler
-	// this is regenerated each time in case routes are added during runtime
-	// not generated if a previous handler is already set
+	// last ditch effort is to use our own generated handlers
+	// not used if a previous handler is already set
 	if ex.handler == nil {
-		ex.handler = r.methodNotAllowed()
+		if method == http.MethodOptions && r.optionsHandler != nil {
+			ex.handler = r.optionsHandler
+			ex.kind = MatchOptions
+			ex.setEndpoint(nil, "")
+		} else if r.notAllowedHandler != nil {
+			ex.handler = r.notAllowedHandler
+			ex.kind = MatchMethodNotAllowed
+			ex.setEndpoint(nil, "")
+		}
 	}
 	return
 }
 
+// setHandler saves the handler for the method and rebuilds the generated
+// handlers that depend on the set of registered methods.
+func (r *Route) setHandler(method string, handler http.Handler) {
+	r.handlers[method] = handler
+	r.setTypes(method, handler)
+
+	// middleware bound to a previous handler doesn't carry over
+	delete(r.endpointMiddleware, method)
+	delete(r.groupMiddleware, method)
+
+	// build a fresh list as generated handlers may still be serving the old one
+	methods := make([]string, 0, len(r.handlers))
+	for m := range r.handlers {
+		if m != methodAny && m != notFound {
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	r.methods = methods
+
+	r.allowed = r.allowedMethods()
+	r.notAllowedHandler = r.methodNotAllowed()
+	r.optionsHandler = r.defaultOptions()
+
+	r.version.invalidate()
+}
+
+// bindMiddleware binds middleware to the handler registered for the method, so that it is only run
+// when that handler is used.
+func (r *Route) bindMiddleware(method string, mids []*middlewareForVerb) {
+	if len(mids) == 0 {
+		return
+	}
+	if r.endpointMiddleware == nil {
+		r.endpointMiddleware = make(map[string][]*middlewareForVerb)
+	}
+	r.endpointMiddleware[method] = append(r.endpointMiddleware[method], mids...)
+	r.version.invalidate()
+}
+
+// boundMiddleware prepares middleware given with a handler to be bound to it
+func boundMiddleware(mids []Middleware) []*middlewareForVerb {
+	bound := make([]*middlewareForVerb, 0, len(mids))
+	for _, m := range mids {
+		bound = append(bound, &middlewareForVerb{
+			mid:  m,
+			verb: flagAny,
+		})
+	}
+	return bound
+}
+
 // Route walks down the route tree following pattern and returns either a new or previously
 // existing node that represents that specific path.
 func (r *Route) Route(path string) *Route {
@@ -340,9 +659,12 @@ func (r *Route) create(path []string, parentPath string) *Route {
 
 	// child can't create it, so we will
 	newRoute := newRoute()
+	newRoute.version = r.version
+	r.version.invalidate()
 
 	// set the pattern name
 	newRoute.pattern = path[1]
+	newRoute.parent = r
 	newRoute.fullPath = r.fullPath + "/" + path[1]
 
 	// check if it's a path param
@@ -365,8 +687,10 @@ func (r *Route) create(path []string, parentPath string) *Route {
 	} else {
 		// Just a regular child
 		r.children = append(r.children, newRoute)
+		anchor := r.literalAnchor()
+		anchor.literals.insert(newRoute.fullPath[len(anchor.fullPath)+1:], newRoute)
 
-		// sort children alphabetically for efficient run time searching
+		// keep children sorted alphabetically, so they're always listed in the same order
 		sort.Sort(r.children)
 	}
 
@@ -374,6 +698,15 @@ func (r *Route) create(path []string, parentPath string) *Route {
 	return newRoute.create(path[1:], r.fullPath)
 }
 
+// literalAnchor returns the route whose radix tree indexes the literal children of this one,
+// the closest of it or its parents that is the root or a path parameter
+func (r *Route) literalAnchor() *Route {
+	for !r.isParam && r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
 // stringRoutes returns the stringRoutes representation of this route and all below it.
 func (r *Route) stringRoutes(routes *[]string) {
 
@@ -392,6 +725,7 @@ func (r *Route) stringRoutes(routes *[]string) {
 		for method := range r.handlers {
 			methods = append(methods, method)
 		}
+		sort.Strings(methods)
 		thisRoute = thisRoute + strings.Join(methods, ", ") + "]"
 		*routes = append(*routes, thisRoute)
 	}
@@ -432,6 +766,7 @@ func (r *Route) Middleware(m Middleware) *Route {
 		mid:  m,
 		verb: flagAny,
 	})
+	r.version.invalidate()
 	return r
 }
 
@@ -440,61 +775,148 @@ func (r *Route) Middleware(m Middleware) *Route {
 // Verbs are case sensitive, and should use the `http.Method*` constants.
 // Panics if any of the verbs provided are unknown.
 func (r *Route) MiddlewareFor(m Middleware, verbs ...string) *Route {
+	if mid := newMiddlewareFor(m, verbs); mid != nil {
+		r.middleware = append(r.middleware, mid)
+	}
+	r.version.invalidate()
+	return r
+}
 
-	// Equivalent to none
-	if len(verbs) == 0 {
-		return r
+// MiddlewareExceptFor adds a middleware to this node, but will only be executed
+// for requests that are not in the list of verbs.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (r *Route) MiddlewareExceptFor(m Middleware, verbs ...string) *Route {
+	if mid := newMiddlewareExceptFor(m, verbs); mid != nil {
+		r.middleware = append(r.middleware, mid)
 	}
+	r.version.invalidate()
+	return r
+}
 
-	f := verbFlag(0)
-	for _, verb := range verbs {
-		f = f | getVerbFlagForMethod(verb)
+// LocalMiddleware adds a middleware to this node that is only executed for requests to this route,
+// not for requests to any routes further down the path.
+func (r *Route) LocalMiddleware(m Middleware) *Route {
+	r.localMiddleware = append(r.localMiddleware, &middlewareForVerb{
+		mid:  m,
+		verb: flagAny,
+	})
+	r.version.invalidate()
+	return r
+}
+
+// LocalMiddlewareFor adds a middleware to this node that is only executed for requests to this route
+// with the verb specified, not for requests to any routes further down the path.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (r *Route) LocalMiddlewareFor(m Middleware, verbs ...string) *Route {
+	if mid := newMiddlewareFor(m, verbs); mid != nil {
+		r.localMiddleware = append(r.localMiddleware, mid)
 	}
+	r.version.invalidate()
+	return r
+}
 
-	// we don't check if this is equivalent to flagAny since a
-	// fully loaded flag set is the same as the flagAny
+// mustIdentify panics if the middleware can't be matched by SkipMiddleware
+func mustIdentify(m Middleware) {
+	if !identifiable(m) {
+		panic(fmt.Sprintf("powermux: can't skip middleware of type %T by value, use Tag and Without", untag(m)))
+	}
+}
 
-	r.middleware = append(r.middleware, &middlewareForVerb{
+// SkipMiddleware stops a middleware added further up the tree from being executed for requests
+// to this route and every route below it.
+//
+// Middleware is matched by equality. Functions, and middleware of types that can't be compared, can't
+// be told apart from others like them, so they must be excluded with Tag and Without instead.
+// Panics if the middleware can't be matched.
+func (r *Route) SkipMiddleware(m Middleware) *Route {
+	mustIdentify(m)
+	r.skips = append(r.skips, &middlewareSkip{
 		mid:  m,
-		verb: f,
+		verb: flagAny,
 	})
+	r.version.invalidate()
+	return r
+}
 
+// SkipMiddlewareFor stops a middleware added further up the tree from being executed for requests
+// to this route and every route below it with the verb specified.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown, or if the middleware can't be matched.
+func (r *Route) SkipMiddlewareFor(m Middleware, verbs ...string) *Route {
+	mustIdentify(m)
+	if f := getVerbFlagForMethods(verbs); f != 0 {
+		r.skips = append(r.skips, &middlewareSkip{
+			mid:  m,
+			verb: f,
+		})
+	}
+	r.version.invalidate()
 	return r
+}
 
+// Without stops all middleware labelled with the tag further up the tree from being executed
+// for requests to this route and every route below it.
+// Middleware is labelled with Tag.
+func (r *Route) Without(tag string) *Route {
+	r.skips = append(r.skips, &middlewareSkip{
+		tag:  tag,
+		verb: flagAny,
+	})
+	r.version.invalidate()
+	return r
 }
 
-// MiddlewareExceptFor adds a middleware to this node, but will only be executed
-// for requests that are not in the list of verbs.
+// WithoutFor stops all middleware labelled with the tag further up the tree from being executed
+// for requests to this route and every route below it with the verb specified.
 // Verbs are case sensitive, and should use the `http.Method*` constants.
 // Panics if any of the verbs provided are unknown.
-func (r *Route) MiddlewareExceptFor(m Middleware, verbs ...string) *Route {
-
-	// Equivalent to any
-	if len(verbs) == 0 {
-		return r.Middleware(m)
+func (r *Route) WithoutFor(tag string, verbs ...string) *Route {
+	if f := getVerbFlagForMethods(verbs); f != 0 {
+		r.skips = append(r.skips, &middlewareSkip{
+			tag:  tag,
+			verb: f,
+		})
 	}
+	r.version.invalidate()
+	return r
+}
 
-	// build the list as if we are calculating For
-	f := verbFlag(0)
-	for _, verb := range verbs {
-		f = f | getVerbFlagForMethod(verb)
-	}
+// Use adds a standard func(http.Handler) http.Handler middleware to this Route.
+// It is executed in the same order as middleware added with Middleware.
+func (r *Route) Use(m func(http.Handler) http.Handler) *Route {
+	return r.Middleware(Adapt(m))
+}
 
-	// then invert to get ExceptFor
-	f = ^f
+// UseFor adds a standard func(http.Handler) http.Handler middleware to this node,
+// but will only be executed for requests with the verb specified.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (r *Route) UseFor(m func(http.Handler) http.Handler, verbs ...string) *Route {
+	return r.MiddlewareFor(Adapt(m), verbs...)
+}
 
-	// Equivalent to none
-	if f == 0 {
-		return r
-	}
+// UseExceptFor adds a standard func(http.Handler) http.Handler middleware to this node,
+// but will only be executed for requests that are not in the list of verbs.
+// Verbs are case sensitive, and should use the `http.Method*` constants.
+// Panics if any of the verbs provided are unknown.
+func (r *Route) UseExceptFor(m func(http.Handler) http.Handler, verbs ...string) *Route {
+	return r.MiddlewareExceptFor(Adapt(m), verbs...)
+}
 
+// MiddlewareIf adds a middleware to this node, but will only be executed for requests
+// the predicate holds for.
+// The predicate is evaluated once the request has been routed, before any middleware runs, and the middleware
+// is left out of the chain if it doesn't hold.
+func (r *Route) MiddlewareIf(p Predicate, m Middleware) *Route {
 	r.middleware = append(r.middleware, &middlewareForVerb{
 		mid:  m,
-		verb: f,
+		verb: flagAny,
+		cond: p,
 	})
-
+	r.version.invalidate()
 	return r
-
 }
 
 // MiddlewareExceptForOptions is shorthand for MiddlewareExceptFor with
@@ -510,59 +932,69 @@ func (r *Route) MiddlewareFunc(m MiddlewareFunc) *Route {
 
 // Any registers a catch-all handler for any method sent to this route.
 // This takes lower precedence than a specific method match.
-func (r *Route) Any(handler http.Handler) *Route {
-	r.handlers[methodAny] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Any(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(methodAny, handler)
+	r.bindMiddleware(methodAny, boundMiddleware(mids))
 	return r
 }
 
 // AnyFunc registers a plain function as a catch-all handler
 // for any method sent to this route.
 // This takes lower precedence than a specific method match.
-func (r *Route) AnyFunc(f http.HandlerFunc) *Route {
-	return r.Any(http.HandlerFunc(f))
+func (r *Route) AnyFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Any(http.HandlerFunc(f), mids...)
 }
 
 // Post adds a handler for POST methods to this route.
-func (r *Route) Post(handler http.Handler) *Route {
-	r.handlers[http.MethodPost] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Post(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodPost, handler)
+	r.bindMiddleware(http.MethodPost, boundMiddleware(mids))
 	return r
 }
 
 // PostFunc adds a plain function as a handler
 // for POST methods to this route.
-func (r *Route) PostFunc(f http.HandlerFunc) *Route {
-	return r.Post(http.HandlerFunc(f))
+func (r *Route) PostFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Post(http.HandlerFunc(f), mids...)
 }
 
 // Put adds a handler for PUT methods to this route.
-func (r *Route) Put(handler http.Handler) *Route {
-	r.handlers[http.MethodPut] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Put(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodPut, handler)
+	r.bindMiddleware(http.MethodPut, boundMiddleware(mids))
 	return r
 }
 
 // PutFunc adds a plain function as a handler
 // for PUT methods to this route.
-func (r *Route) PutFunc(f http.HandlerFunc) *Route {
-	return r.Put(http.HandlerFunc(f))
+func (r *Route) PutFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Put(http.HandlerFunc(f), mids...)
 }
 
 // Patch adds a handler for PATCH methods to this route.
-func (r *Route) Patch(handler http.Handler) *Route {
-	r.handlers[http.MethodPatch] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Patch(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodPatch, handler)
+	r.bindMiddleware(http.MethodPatch, boundMiddleware(mids))
 	return r
 }
 
 // PatchFunc adds a plain function as a handler
 // for PATCH methods to this route.
-func (r *Route) PatchFunc(f http.HandlerFunc) *Route {
-	return r.Patch(http.HandlerFunc(f))
+func (r *Route) PatchFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Patch(http.HandlerFunc(f), mids...)
 }
 
 // Get adds a handler for GET methods to this route.
 // GET handlers will also be called for HEAD requests
 // if no specific HEAD handler is registered.
-func (r *Route) Get(handler http.Handler) *Route {
-	r.handlers[http.MethodGet] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Get(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodGet, handler)
+	r.bindMiddleware(http.MethodGet, boundMiddleware(mids))
 	return r
 }
 
@@ -570,51 +1002,59 @@ func (r *Route) Get(handler http.Handler) *Route {
 // for GET methods to this route.
 // GET handlers will also be called for HEAD requests
 // if no specific HEAD handler is registered.
-func (r *Route) GetFunc(f http.HandlerFunc) *Route {
-	return r.Get(http.HandlerFunc(f))
+func (r *Route) GetFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Get(http.HandlerFunc(f), mids...)
 }
 
 // Delete adds a handler for DELETE methods to this route.
-func (r *Route) Delete(handler http.Handler) *Route {
-	r.handlers[http.MethodDelete] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Delete(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodDelete, handler)
+	r.bindMiddleware(http.MethodDelete, boundMiddleware(mids))
 	return r
 }
 
 // DeleteFunc adds a plain function as a handler
 // for DELETE methods to this route.
-func (r *Route) DeleteFunc(f http.HandlerFunc) *Route {
-	return r.Delete(http.HandlerFunc(f))
+func (r *Route) DeleteFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Delete(http.HandlerFunc(f), mids...)
 }
 
 // Head adds a handler for HEAD methods to this route.
-func (r *Route) Head(handler http.Handler) *Route {
-	r.handlers[http.MethodHead] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Head(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodHead, handler)
+	r.bindMiddleware(http.MethodHead, boundMiddleware(mids))
 	return r
 }
 
 // HeadFunc adds a plain function as a handler
 // for HEAD methods to this route.
-func (r *Route) HeadFunc(f http.HandlerFunc) *Route {
-	return r.Head(http.HandlerFunc(f))
+func (r *Route) HeadFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Head(http.HandlerFunc(f), mids...)
 }
 
 // Connect adds a handler for CONNECT methods to this route.
-func (r *Route) Connect(handler http.Handler) *Route {
-	r.handlers[http.MethodConnect] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Connect(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodConnect, handler)
+	r.bindMiddleware(http.MethodConnect, boundMiddleware(mids))
 	return r
 }
 
 // ConnectFunc adds a plain function as a handler
 // for CONNECT methods to this route.
-func (r *Route) ConnectFunc(f http.HandlerFunc) *Route {
-	return r.Connect(http.HandlerFunc(f))
+func (r *Route) ConnectFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Connect(http.HandlerFunc(f), mids...)
 }
 
 // Options adds a handler for OPTIONS methods to this route.
 // This handler will also be called for any routes further down the path
 // from this point if no other OPTIONS handlers are registered below.
-func (r *Route) Options(handler http.Handler) *Route {
-	r.handlers[http.MethodOptions] = handler
+// Middleware given is bound to the handler, and only executed when it is used.
+func (r *Route) Options(handler http.Handler, mids ...Middleware) *Route {
+	r.setHandler(http.MethodOptions, handler)
+	r.bindMiddleware(http.MethodOptions, boundMiddleware(mids))
 	return r
 }
 
@@ -622,18 +1062,37 @@ func (r *Route) Options(handler http.Handler) *Route {
 // for OPTIONS methods to this route.
 // This handler will also be called for any routes further down the path
 // from this point if no other OPTIONS handlers are registered below.
-func (r *Route) OptionsFunc(f http.HandlerFunc) *Route {
-	return r.Options(http.HandlerFunc(f))
+func (r *Route) OptionsFunc(f http.HandlerFunc, mids ...Middleware) *Route {
+	return r.Options(http.HandlerFunc(f), mids...)
 }
 
 // NotFound adds a handler for requests that do not correspond to a route.
 // This handler will also be called for any routes further down the path
 // from this point if no other not found handlers are registered below.
 func (r *Route) NotFound(handler http.Handler) *Route {
-	r.handlers[notFound] = handler
+	r.setHandler(notFound, handler)
 	return r
 }
 
+// BadRequest adds a handler for requests with a malformed path.
+// The handler can retrieve the reason the path was rejected with RequestError.
+// This handler will also be called for any routes further down the path
+// from this point if no other bad request handlers are registered below.
+func (r *Route) BadRequest(handler http.Handler) *Route {
+	r.badRequest = handler
+	r.version.invalidate()
+	return r
+}
+
+// BadRequestFunc adds a plain function as a handler for requests
+// with a malformed path.
+// The handler can retrieve the reason the path was rejected with RequestError.
+// This handler will also be called for any routes further down the path
+// from this point if no other bad request handlers are registered below.
+func (r *Route) BadRequestFunc(f http.HandlerFunc) *Route {
+	return r.BadRequest(http.HandlerFunc(f))
+}
+
 // NotFoundFunc adds a plain function as a handler for requests
 // that do not correspond to a route.
 // This handler will also be called for any routes further down the path
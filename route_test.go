@@ -278,3 +278,27 @@ func TestFlagMatches_Empty(t *testing.T) {
 		t.Error("Should never match a null flag")
 	}
 }
+
+func TestRoute_InvalidEscape(t *testing.T) {
+	r := newRoute()
+	r.Route("/users/:id").Get(wrongHandler)
+
+	ex := newExecution()
+	r.execute(ex, http.MethodGet, "/users/%zz")
+
+	if ex.err == nil {
+		t.Fatal("No routing error set")
+	}
+
+	if ex.err.Kind != ErrInvalidEscape {
+		t.Error("Wrong error kind", ex.err.Kind)
+	}
+
+	if ex.err.Param != "id" || ex.err.Segment != "%zz" {
+		t.Error("Wrong error details", ex.err)
+	}
+
+	if ex.handler != nil {
+		t.Error("Handler should not be set for malformed paths")
+	}
+}
@@ -2,8 +2,8 @@ package powermux
 
 import (
 	"bytes"
-	"context"
 	"net/http"
+	"sort"
 )
 
 // ServeMux is the multiplexer for http requests
@@ -11,27 +11,39 @@ type ServeMux struct {
 	baseRoute     *Route
 	hostRoutes    map[string]*Route
 	executionPool *executionPool
+	problems      ProblemRenderer
+	hostProblems  map[string]ProblemRenderer
+	authorizer    Authorizer
+	static        staticPaths
+	suggestions   suggestRoutes
+	recovering    bool
+	panicReporter PanicReporter
 }
 
 // ctxKey is the key type used for path parameters in the request context
 type ctxKey string
 
-var (
-	executionKey = ctxKey("ex")
-)
+// executionKey is a constant so using it as a context key doesn't allocate
+const executionKey = ctxKey("ex")
 
 func getRequestExecution(req *http.Request) *routeExecution {
 	ex := req.Context().Value(executionKey).(*routeExecution)
 	return ex
 }
 
+// lookupExecution returns the execution of a request being served by a ServeMux, or nil if there isn't one
+func lookupExecution(req *http.Request) *routeExecution {
+	ex, _ := req.Context().Value(executionKey).(*routeExecution)
+	return ex
+}
+
 // PathParam gets named path parameters and their values from the request
 //
 // the path '/users/:name' given '/users/andrew' will have `PathParam(r, "name")` => `"andrew"`
 // unset values return an empty stringRoutes
 func PathParam(req *http.Request, name string) (value string) {
 	ex := getRequestExecution(req)
-	return ex.params[name]
+	return ex.param(name)
 }
 
 // PathParams returns the map of all path parameters and their values from the request.
@@ -39,9 +51,9 @@ func PathParam(req *http.Request, name string) (value string) {
 // Altering the values of this map will not affect future calls to PathParam and PathParams.
 func PathParams(req *http.Request) (params map[string]string) {
 	ex := getRequestExecution(req)
-	params = make(map[string]string)
-	for k, v := range ex.params {
-		params[k] = v
+	params = make(map[string]string, len(ex.params))
+	for _, p := range ex.params {
+		params[p.name] = p.value
 	}
 	return
 }
@@ -53,53 +65,161 @@ func RequestPath(req *http.Request) (value string) {
 	return ex.pattern
 }
 
+// AllowedMethods returns the methods supported by the route that matched the request,
+// as advertised in the "Allow" header of generated OPTIONS responses.
+//
+// This lets OPTIONS handlers registered higher up the tree answer for the specific route requested.
+// Requests that did not match a route return an empty list.
+func AllowedMethods(req *http.Request) (methods []string) {
+	ex := getRequestExecution(req)
+	methods = make([]string, len(ex.allowed))
+	copy(methods, ex.allowed)
+	return
+}
+
+// RequestError returns the reason the request path was rejected by the router.
+// Requests with a well formed path return nil.
+func RequestError(req *http.Request) *RoutingError {
+	ex := getRequestExecution(req)
+	return ex.err
+}
+
+// DetachParams stops the request and its path parameters, route path and other routing details from being
+// reused once ServeHTTP returns, so they can be used by goroutines that outlive the handler.
+// It returns the request.
+//
+// It must be called before the handler returns. Requests derived from the request with WithContext are
+// detached as well.
+func DetachParams(req *http.Request) *http.Request {
+	ex := getRequestExecution(req)
+	ex.detached = true
+	return req
+}
+
 // NewServeMux creates a new multiplexer, and sets up a default not found handler
 func NewServeMux() *ServeMux {
 	s := &ServeMux{
 		baseRoute:     newRoute(),
 		hostRoutes:    make(map[string]*Route),
 		executionPool: newExecutionPool(),
+		hostProblems:  make(map[string]ProblemRenderer),
 	}
-	s.NotFound(http.NotFoundHandler())
+	s.NotFound(notFoundHandler{})
+	s.BadRequest(defaultBadRequest)
 	return s
 }
 
 func (s *ServeMux) getAll(r *http.Request, ex *routeExecution) {
+	s.route(r, ex)
+
+	s.authorize(ex)
+
+	// only keep the conditional middleware that applies
+	ex.applyConditions(r)
+
+	// render generated responses as problems if requested
+	if renderer := s.problemRenderer(r.URL.Host); renderer != nil {
+		ex.renderer = renderer
+		if gen, ok := ex.handler.(problemHandler); ok {
+			ex.problem = problemResponse{
+				ex:       ex,
+				gen:      gen,
+				renderer: renderer,
+			}
+			ex.handler = &ex.problem
+		}
+	}
+
+	return
+}
+
+// route finds the route for the request, and the handler that serves it
+func (s *ServeMux) route(r *http.Request, ex *routeExecution) {
 	path := r.URL.EscapedPath()
+	ex.method = r.Method
 
-	// fill it
+	root := s.baseRoute
 	if route, ok := s.hostRoutes[r.URL.Host]; ok {
-		route.execute(ex, r.Method, path)
-	} else {
-		s.baseRoute.execute(ex, r.Method, path)
+		ex.host = r.URL.Host
+		root = route
+	}
+
+	// fully literal paths are only routed once for each method, unless the routing is being traced
+	var static *staticPath
+	if !staticPathsDisabled && ex.trace == nil {
+		static = s.static.get(s).paths[root][path]
+		if static != nil && static.load(ex) {
+			return
+		}
 	}
 
-	// fall back on not found handler if necessary
-	if ex.handler == nil {
+	// fill it
+	root.execute(ex, r.Method, path)
+
+	if ex.err != nil {
+		// malformed paths are always bad requests
+		ex.err.Path = path
+		ex.handler = ex.badRequest
+		if ex.handler == nil {
+			ex.handler = defaultBadRequest
+		}
+		ex.pattern = ""
+		ex.allowed = nil
+		ex.kind = MatchBadRequest
+	} else if ex.handler == nil {
+		// fall back on not found handler if necessary
 		ex.handler = ex.notFound
+		ex.kind = MatchNotFound
 	}
 
-	return
+	if static != nil {
+		static.save(ex)
+	}
+}
+
+// authorize enforces the requirements of the matched route whenever a registered handler is used,
+// including OPTIONS handlers inherited from a route above. Generated responses aren't protected.
+func (s *ServeMux) authorize(ex *routeExecution) {
+	registered := ex.endpoint != nil && (ex.kind == MatchRoute || ex.kind == MatchOptions)
+	if s.authorizer != nil && registered && len(ex.requirements) > 0 {
+		ex.auth = authorizedHandler{
+			requirements: ex.requirements,
+			authorizer:   s.authorizer,
+			handler:      ex.handler,
+		}
+		ex.handler = &ex.auth
+	}
 }
 
 // ServeHTTP dispatches the request to the handler whose pattern most closely matches the request URL.
+//
+// Middleware and handlers are served a copy of the request that is reused once ServeHTTP returns,
+// so it must not be used after the handler returns unless it was passed to DetachParams.
 func (s *ServeMux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	// Get a route execution from the pool
+	// Get a route execution from the pool, and return it even if a handler panics
 	ex := s.executionPool.Get()
+	defer s.executionPool.Put(ex)
+
+	// Watch the response so a panic is only answered if it hasn't been started,
+	// including panics from predicates and authorizers while routing
+	if s.recovering {
+		ex.writer.ResponseWriter = rw
+		rw = &ex.writer
+		defer s.recoverPanic(ex, req)
+	}
 
 	s.getAll(req, ex)
 
-	// Save the execution
-	ctx := context.WithValue(req.Context(), executionKey, ex)
-
-	// Save context into request
-	req = req.WithContext(ctx)
+	// Apply any CORS policy, which may answer preflight requests itself unless an OPTIONS handler was registered
+	if ex.cors != nil && ex.cors.serve(rw, req, ex.allowed, ex.endpointKey == http.MethodOptions) {
+		return
+	}
 
-	// Run a middleware/handler closure to nest all middleware
-	f := getNextMiddleware(ex.middleware, ex.handler)
-	f(rw, req)
+	// Save the execution into the request's context
+	req = ex.attach(req)
 
-	s.executionPool.Put(ex)
+	// Run the middleware and handler
+	s.chain(ex).ServeHTTP(rw, req)
 }
 
 // Handle registers the handler for the given pattern.
@@ -183,7 +303,9 @@ func (s *ServeMux) RouteHost(host, path string) *Route {
 	r, ok := s.hostRoutes[host]
 	if !ok {
 		r = newRoute()
+		r.version = s.baseRoute.version
 		s.hostRoutes[host] = r
+		r.version.invalidate()
 	}
 	return r.Route(path)
 }
@@ -193,7 +315,18 @@ func (s *ServeMux) NotFound(handler http.Handler) {
 	s.baseRoute.NotFound(handler)
 }
 
-// String returns a list of all routes registered with this server
+// BadRequest sets the default handler for requests with a malformed path.
+// The handler can retrieve the reason the path was rejected with RequestError.
+func (s *ServeMux) BadRequest(handler http.Handler) {
+	s.baseRoute.BadRequest(handler)
+}
+
+// CORS sets the default Cross-Origin Resource Sharing policy for the server
+func (s *ServeMux) CORS(policy *CORS) {
+	s.baseRoute.CORS(policy)
+}
+
+// String returns a list of all routes registered with this server, sorted the same way every time
 func (s *ServeMux) String() string {
 	routes := make([]string, 0, 1)
 	s.baseRoute.stringRoutes(&routes)
@@ -204,9 +337,15 @@ func (s *ServeMux) String() string {
 		buf.WriteString(route + "\n")
 	}
 
-	for host, baseRoute := range s.hostRoutes {
+	hosts := make([]string, 0, len(s.hostRoutes))
+	for host := range s.hostRoutes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
 		routes = routes[0:0]
-		baseRoute.stringRoutes(&routes)
+		s.hostRoutes[host].stringRoutes(&routes)
 		for _, route := range routes {
 			buf.WriteString(host + route + "\n")
 		}
@@ -1,9 +1,12 @@
 package powermux
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -402,6 +405,57 @@ func TestServeMux_HandleOptionsAtDepth(t *testing.T) {
 	}
 }
 
+func TestServeMux_OptionsGenerated(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/a").Get(wrongHandler).Delete(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/a", nil)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Error("Wrong response code", rec.Code)
+	}
+
+	if allow := rec.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Error("Wrong Allow header", allow)
+	}
+}
+
+func TestServeMux_OptionsGeneratedNotFound(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/a").Get(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/a/b", nil)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Error("Wrong response code", rec.Code)
+	}
+}
+
+func TestServeMux_OptionsAllowedMethods(t *testing.T) {
+	s := NewServeMux()
+
+	var allowed []string
+	s.Route("/a").OptionsFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed = AllowedMethods(r)
+	})
+	s.Route("/a/b").Put(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/a/b", nil)
+	s.ServeHTTP(nil, req)
+
+	if strings.Join(allowed, ", ") != "OPTIONS, PUT" {
+		t.Error("Wrong allowed methods", allowed)
+	}
+}
+
 // Ensure routing is not performed on decoded path components
 func TestServeMux_EncodedPathComponent(t *testing.T) {
 	s := NewServeMux()
@@ -741,6 +795,158 @@ func TestServeMux_MiddlewareExceptFor_None(t *testing.T) {
 		t.Fatal("Wrong number of middlewares returned. Expected 0 got", len(mids))
 	}
 }
+func TestServeMux_LocalMiddleware(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/search").
+		LocalMiddleware(mid1).
+		LocalMiddlewareFor(mid2, http.MethodPost).
+		Get(rightHandler).
+		Post(rightHandler)
+	s.Route("/search/suggest").Get(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/search", body: "mid1right"},
+		{method: http.MethodPost, path: "/search", body: "mid1mid2right"},
+		{method: http.MethodGet, path: "/search/suggest", body: "right"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != tt.body {
+			t.Errorf("Wrong execution for %s %s, expected=%s actual=%s", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
+
+func TestServeMux_HandlerMiddleware(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/a").
+		Middleware(mid1).
+		Get(rightHandler, mid2).
+		PostFunc(dummyHandlerFunc("post"))
+	s.Route("/a/b").Get(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/a", body: "mid1mid2right"},
+		{method: http.MethodHead, path: "/a", body: "mid1mid2right"},
+		{method: http.MethodPost, path: "/a", body: "mid1post"},
+		{method: http.MethodGet, path: "/a/b", body: "mid1right"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != tt.body {
+			t.Errorf("Wrong execution for %s %s, expected=%s actual=%s", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
+
+func TestServeMux_SkipMiddleware(t *testing.T) {
+	s := NewServeMux()
+
+	logging := MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		io.WriteString(w, "log")
+		n(w, r)
+	})
+
+	s.Route("/api").
+		Middleware(Tag(mid1, "auth")).
+		MiddlewareFor(mid2, http.MethodGet, http.MethodPost).
+		Middleware(Tag(logging, "logging"))
+	s.Route("/api/users").Get(rightHandler)
+	s.Route("/api/health").
+		Without("auth").
+		SkipMiddlewareFor(mid2, http.MethodGet).
+		Without("logging").
+		Get(rightHandler).
+		Post(rightHandler)
+	s.Route("/api/health/deep").Middleware(logging).Get(rightHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/api/users", body: "mid1mid2logright"},
+		{method: http.MethodGet, path: "/api/health", body: "right"},
+		{method: http.MethodPost, path: "/api/health", body: "mid2right"},
+		{method: http.MethodGet, path: "/api/health/deep", body: "logright"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != tt.body {
+			t.Errorf("Wrong execution for %s %s, expected=%s actual=%s", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
+
+// instanceMiddleware writes its name, and is identified by its pointer
+type instanceMiddleware struct {
+	name string
+}
+
+func (m *instanceMiddleware) ServeHTTPMiddleware(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+	io.WriteString(w, m.name)
+	n(w, r)
+}
+
+func (m *instanceMiddleware) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, m.name)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestServeMux_SkipMiddlewareInstance(t *testing.T) {
+	s := NewServeMux()
+
+	// equal instances are still different middleware
+	a, b := &instanceMiddleware{name: "log"}, &instanceMiddleware{name: "log"}
+	s.Route("/api").Middleware(a).Middleware(b)
+	s.Route("/api/health").SkipMiddleware(a).Get(rightHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if rec.Body.String() != "logright" {
+		t.Error("Wrong execution", rec.Body.String())
+	}
+
+	// method values of different instances share their code, so can't be skipped by value
+	for _, m := range []Middleware{
+		Adapt(a.wrap),
+		Tag(MiddlewareFunc(a.ServeHTTPMiddleware), "log"),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Skipping %T did not panic", untag(m))
+				}
+			}()
+			s.Route("/api/health").SkipMiddleware(m)
+		}()
+	}
+}
+
 func TestServeMux_MiddlewareDouble(t *testing.T) {
 	s := NewServeMux()
 
@@ -860,6 +1066,80 @@ func TestPathParamsImmutable(t *testing.T) {
 	s.ServeHTTP(nil, req)
 }
 
+func TestPathParamsRepeated(t *testing.T) {
+	s := NewServeMux()
+
+	var param string
+	var params map[string]string
+
+	handler := func(res http.ResponseWriter, r *http.Request) {
+		param = PathParam(r, "id")
+		params = PathParams(r)
+	}
+
+	s.Route("/:id/files/:id").GetFunc(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/a/files/b", nil)
+
+	s.ServeHTTP(nil, req)
+
+	if param != "b" || params["id"] != "b" || len(params) != 1 {
+		t.Error("Repeated param should use the last value", param, params)
+	}
+}
+
+func TestServeMux_RequestContext(t *testing.T) {
+	s := NewServeMux()
+
+	type key string
+
+	var reqCtx context.Context
+	handler := func(res http.ResponseWriter, r *http.Request) {
+		reqCtx = r.Context()
+		if r.Context().Value(key("k")) != "v" {
+			t.Error("Request context value lost")
+		}
+		if r.Context().Err() != nil {
+			t.Error("Request context canceled while serving")
+		}
+
+		// contexts derived by handlers still carry the execution
+		r = r.WithContext(context.WithValue(r.Context(), key("other"), "x"))
+		if PathParam(r, "id") != "andrew" {
+			t.Error("Param lost from derived context")
+		}
+	}
+
+	s.Route("/:id").GetFunc(handler)
+
+	ctx := context.WithValue(context.Background(), key("k"), "v")
+	s.ServeHTTP(nil, httptest.NewRequest(http.MethodGet, "/andrew", nil).WithContext(ctx))
+
+	if reqCtx.Err() == nil {
+		t.Error("Request context not canceled after serving")
+	}
+}
+
+func TestServeMux_Allocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Allocations aren't reliable with the race detector")
+	}
+
+	s := NewServeMux()
+	s.Route("/static/route").Get(emptyHandle)
+	s.Route("/users/:id/*").Get(emptyHandle)
+
+	for _, path := range []string{"/static/route", "/users/andrew/files"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			s.ServeHTTP(nil, req)
+		})
+		if allocs != 0 {
+			t.Error("Serving", path, "allocates", allocs)
+		}
+	}
+}
+
 func containsStr(strs []string, s string) int {
 	for i, str := range strs {
 		if strings.HasPrefix(str, s+"\t") {
@@ -945,6 +1225,115 @@ func TestServeMux_NotFoundDepth(t *testing.T) {
 	}
 }
 
+func TestServeMux_BadRequest(t *testing.T) {
+	s := NewServeMux()
+
+	var routingErr *RoutingError
+	s.BadRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routingErr = RequestError(r)
+	}))
+	s.Route("/users/info").Get(wrongHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/users//info", nil)
+	s.ServeHTTP(nil, req)
+
+	if routingErr == nil {
+		t.Fatal("Bad request handler not called")
+	}
+
+	if routingErr.Kind != ErrDoubleSlash {
+		t.Error("Wrong error kind", routingErr.Kind)
+	}
+
+	if routingErr.Path != "/users//info" {
+		t.Error("Wrong error path", routingErr.Path)
+	}
+}
+
+func TestServeMux_StringBadRequest(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/users").BadRequest(rightHandler)
+	s.Route("/users/:id").Get(rightHandler)
+
+	// bad request handlers aren't methods, and don't make a route worth listing
+	if str := s.String(); str != "/\t[NOT_FOUND]\n/users/:id\t[GET]\n" {
+		t.Errorf("Wrong routes:\n%s", str)
+	}
+}
+
+func TestServeMux_BadRequestParam(t *testing.T) {
+	s := NewServeMux()
+
+	var routingErr *RoutingError
+	s.BadRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routingErr = RequestError(r)
+	}))
+
+	var parsed interface{}
+	s.Route("/users/:id").
+		Constrain(func(id string) bool { return id != "root" }).
+		Parse(func(id string) (interface{}, error) { return strconv.Atoi(id) }).
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			parsed = ParsedParam(r, "id")
+		})
+	s.Route("/users/*").Get(wrongHandler)
+
+	s.ServeHTTP(nil, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if routingErr != nil || parsed != 42 {
+		t.Error("Wrong parsed param", routingErr, parsed)
+	}
+
+	tests := []struct {
+		path string
+		kind RoutingErrorKind
+	}{
+		{"/users/root", ErrConstraint},
+		{"/users/andrew", ErrParamParse},
+	}
+
+	for _, test := range tests {
+		routingErr = nil
+		s.ServeHTTP(nil, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if routingErr == nil {
+			t.Error("Bad request handler not called for", test.path)
+			continue
+		}
+		if routingErr.Kind != test.kind || routingErr.Param != "id" || routingErr.Path != test.path {
+			t.Error("Wrong error for", test.path, routingErr)
+		}
+	}
+
+	if !errors.Is(routingErr, strconv.ErrSyntax) {
+		t.Error("Parse error not wrapped", routingErr)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Constrained a literal route")
+		}
+	}()
+	s.Route("/users").Constrain(func(string) bool { return true })
+}
+
+func TestServeMux_BadRequestDepth(t *testing.T) {
+	s := NewServeMux()
+	s.BadRequest(wrongHandler)
+	s.Route("/users").BadRequest(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/a//b", nil)
+
+	h, path := s.Handler(req)
+
+	if h != rightHandler {
+		t.Error("Wrong bad request handler returned")
+	}
+
+	// bad requests should return an empty pattern
+	if path != "" {
+		t.Error("Wrong path returned", path)
+	}
+}
+
 func TestServeMux_RouteHost(t *testing.T) {
 	s := NewServeMux()
 
@@ -0,0 +1,92 @@
+package powermux
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// staticPathsDisabled routes every request by walking the tree, so tests can check both ways give the same results
+var staticPathsDisabled = false
+
+// staticIndex finds the routing results for fully literal paths without walking the route tree.
+// It's rebuilt after any route changes.
+type staticIndex struct {
+	version uint64
+	// the literal paths of each tree, by the root of the tree
+	paths map[*Route]map[string]*staticPath
+}
+
+// staticPath holds the routing results for a fully literal path, for each verb once it's been routed
+type staticPath struct {
+	results [9]atomic.Value
+}
+
+// staticPaths holds the index for a ServeMux, and stops it being built more than once at a time
+type staticPaths struct {
+	index atomic.Value
+	lock  sync.Mutex
+}
+
+// get returns the index for the current routes, rebuilding it if they've changed
+func (p *staticPaths) get(s *ServeMux) *staticIndex {
+	version := s.baseRoute.version.load()
+	if index, ok := p.index.Load().(*staticIndex); ok && index.version == version {
+		return index
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// another request may have rebuilt it while we waited
+	if index, ok := p.index.Load().(*staticIndex); ok && index.version == version {
+		return index
+	}
+
+	index := &staticIndex{
+		version: version,
+		paths:   make(map[*Route]map[string]*staticPath, len(s.hostRoutes)+1),
+	}
+
+	index.paths[s.baseRoute] = make(map[string]*staticPath)
+	s.baseRoute.collectStatic(index.paths[s.baseRoute])
+	for _, root := range s.hostRoutes {
+		index.paths[root] = make(map[string]*staticPath)
+		root.collectStatic(index.paths[root])
+	}
+
+	p.index.Store(index)
+	return index
+}
+
+// collectStatic adds this node and every literal node below it to the paths
+func (r *Route) collectStatic(paths map[string]*staticPath) {
+	path := r.fullPath
+	if path == "" {
+		path = "/"
+	}
+	paths[path] = &staticPath{}
+
+	for _, child := range r.children {
+		// an empty pattern is only reached by a trailing slash, which is redirected
+		if child.pattern != "" {
+			child.collectStatic(paths)
+		}
+	}
+}
+
+// load fills the execution with the saved results for its method, and reports if there were any
+func (p *staticPath) load(ex *routeExecution) bool {
+	saved, ok := p.results[verbIndex(getVerbFlagForRequest(ex.method))].Load().(*routeExecution)
+	if !ok {
+		return false
+	}
+	ex.copyRouting(saved)
+	return true
+}
+
+// save keeps the results of routing the execution for its method
+func (p *staticPath) save(ex *routeExecution) {
+	saved := &routeExecution{}
+	saved.copyRouting(ex)
+	p.results[verbIndex(getVerbFlagForRequest(ex.method))].Store(saved)
+}
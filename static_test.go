@@ -0,0 +1,102 @@
+package powermux
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestMain runs every test twice, routing literal paths with the static index and then by walking the tree,
+// so both are held to the same behaviour
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code != 0 {
+		os.Exit(code)
+	}
+
+	// benchmarks only need running once
+	flag.Set("test.bench", "")
+
+	staticPathsDisabled = true
+	os.Exit(m.Run())
+}
+
+func TestStatic_Indexed(t *testing.T) {
+	if staticPathsDisabled {
+		t.Skip("Static paths disabled")
+	}
+
+	s := NewServeMux()
+	s.Route("/a/b").Get(rightHandler)
+	s.Route("/a/:id").Get(wrongHandler)
+	s.Route("/c/*").Get(wrongHandler)
+	s.RouteHost("example.com", "/d").Get(rightHandler)
+
+	paths := s.static.get(s).paths
+	for _, path := range []string{"/", "/a", "/a/b", "/c"} {
+		if paths[s.baseRoute][path] == nil {
+			t.Error("Literal path not indexed", path)
+		}
+	}
+	for _, path := range []string{"/a/:id", "/c/*", "/d"} {
+		if paths[s.baseRoute][path] != nil {
+			t.Error("Path indexed", path)
+		}
+	}
+	if paths[s.hostRoutes["example.com"]]["/d"] == nil {
+		t.Error("Host path not indexed")
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a/b", nil))
+
+	ex := newExecution()
+	ex.method = http.MethodGet
+	if !s.static.get(s).paths[s.baseRoute]["/a/b"].load(ex) || ex.pattern != "/a/b" || ex.kind != MatchRoute {
+		t.Error("Routing results not saved", ex.pattern, ex.kind)
+	}
+}
+
+func TestStatic_Invalidated(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/a/b").Get(rightHandler)
+
+	serve := func(host, path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.URL.Host = host
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if body := serve("", "/a"); body != "404 page not found\n" {
+		t.Fatal("Wrong response", body)
+	}
+
+	// a not found handler set after the path was routed
+	s.Route("/a").NotFound(wrongHandler)
+	if body := serve("", "/a"); body != "wrong" {
+		t.Error("Not found handler added after routing not used", body)
+	}
+
+	// a handler set after the path was routed
+	s.Route("/a").Get(rightHandler)
+	if body := serve("", "/a"); body != "right" {
+		t.Error("Handler added after routing not used", body)
+	}
+
+	// a new node between
+	s.Route("/a").Middleware(mid1)
+	s.Route("/a/b/c").Get(rightHandler)
+	if body := serve("", "/a/b/c"); body != "mid1right" {
+		t.Error("Route added after routing not used", body)
+	}
+
+	// a host tree registered after the path was routed
+	s.RouteHost("example.com", "/a").Get(wrongHandler)
+	if body := serve("example.com", "/a"); body != "wrong" {
+		t.Error("Host added after routing not used", body)
+	}
+}
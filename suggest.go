@@ -0,0 +1,370 @@
+package powermux
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// maxSuggestions is the most routes suggested for a request that wasn't found
+	maxSuggestions = 3
+	// maxSuggestPath is the longest path routes are suggested for, which bounds the work for each request
+	maxSuggestPath = 256
+)
+
+// Suggestion is a registered route close to the path of a request that wasn't found.
+type Suggestion struct {
+	// Pattern is the full pattern of the route
+	Pattern string `json:"pattern"`
+	// Methods are the methods the route has handlers for, sorted, with "ANY" last if it has a handler for any method
+	Methods []string `json:"methods"`
+	// Allowed is set if the route serves the method of the request
+	Allowed bool `json:"allowed"`
+	// Distance is the number of characters that differ between the path and the pattern.
+	// Path parameters match any segment, and wildcards match the rest of the path.
+	Distance int `json:"distance"`
+}
+
+// SuggestNotFound returns a not found handler that suggests the registered routes closest to the request path.
+// Routes are ranked by how many characters of the path differ from the pattern, then by whether they serve the
+// request's method, so a path that exists under another method is still suggested.
+//
+// The suggestions are available to next with Suggestions. If next is nil the suggestions are written in the
+// 404 response, as a problem if the ServeMux renders problems.
+//
+// Suggestions disclose the patterns and methods of registered routes to anyone who asks for a path that doesn't
+// exist, including routes they may not be authorized to use, so only suggest routes where the route table isn't
+// sensitive. Paths longer than 256 bytes get no suggestions, and routes too much longer or shorter than the path
+// are skipped without being compared, so the work for each request is bounded by the number of routes.
+//
+//	mux.NotFound(mux.SuggestNotFound(nil))
+func (s *ServeMux) SuggestNotFound(next http.Handler) http.Handler {
+	return &suggestingHandler{
+		mux:  s,
+		next: next,
+	}
+}
+
+// Suggestions returns the routes suggested for a request that wasn't found, closest first.
+// It's nil unless the request is being served by the handler returned from SuggestNotFound.
+func Suggestions(req *http.Request) []Suggestion {
+	ex := lookupExecution(req)
+	if ex == nil {
+		return nil
+	}
+	return ex.suggestions
+}
+
+// suggestingHandler finds the suggestions for requests that weren't found
+type suggestingHandler struct {
+	mux  *ServeMux
+	next http.Handler
+}
+
+// ServeHTTP finds the suggestions for the request, then serves it with next or the default response
+func (h *suggestingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Host
+	ex := lookupExecution(r)
+	if ex != nil {
+		host = ex.host
+	}
+
+	suggestions := h.mux.suggest(host, r.Method, r.URL.EscapedPath())
+	if ex != nil {
+		ex.suggestions = suggestions
+	}
+
+	if h.next != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if renderer := h.mux.problemRenderer(r.URL.Host); renderer != nil {
+		p := newProblem(r, http.StatusNotFound)
+		if len(suggestions) > 0 {
+			p.Extensions = map[string]interface{}{
+				"suggestions": suggestions,
+			}
+		}
+		renderer.RenderProblem(w, r, p)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintln(w, "404 page not found")
+	if len(suggestions) > 0 {
+		fmt.Fprintln(w, "\nDid you mean:")
+		for _, sug := range suggestions {
+			fmt.Fprintf(w, "\t%s %s\n", strings.Join(sug.Methods, ", "), sug.Pattern)
+		}
+	}
+}
+
+// suggestIndex holds the routes that can be suggested, so the tree isn't walked for every request.
+// It's rebuilt after any route changes.
+type suggestIndex struct {
+	version uint64
+	// the routes with handlers in each tree, in the order they're walked, by the root of the tree
+	routes map[*Route][]*suggestRoute
+}
+
+// suggestRoute is a route that can be suggested, with what's needed to compare it to a path
+type suggestRoute struct {
+	pattern  string
+	segments []string
+	// the methods the route has handlers for, with "ANY" last if it has a handler for any method
+	methods []string
+	any     bool
+	// the cost of all the pattern's segments missing from a path, and the characters of its literal segments
+	length  int
+	literal int
+	// set if the pattern has path parameters or a wildcard, which match segments of any length
+	open bool
+}
+
+// suggestRoutes holds the index for a ServeMux, and stops it being built more than once at a time
+type suggestRoutes struct {
+	index atomic.Value
+	lock  sync.Mutex
+}
+
+// get returns the index for the current routes, rebuilding it if they've changed
+func (p *suggestRoutes) get(s *ServeMux) *suggestIndex {
+	version := s.baseRoute.version.load()
+	if index, ok := p.index.Load().(*suggestIndex); ok && index.version == version {
+		return index
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// another request may have rebuilt it while we waited
+	if index, ok := p.index.Load().(*suggestIndex); ok && index.version == version {
+		return index
+	}
+
+	index := &suggestIndex{
+		version: version,
+		routes:  make(map[*Route][]*suggestRoute, len(s.hostRoutes)+1),
+	}
+
+	index.routes[s.baseRoute] = s.baseRoute.suggestable()
+	for _, root := range s.hostRoutes {
+		index.routes[root] = root.suggestable()
+	}
+
+	p.index.Store(index)
+	return index
+}
+
+// suggestable returns the routes with handlers in this tree
+func (r *Route) suggestable() []*suggestRoute {
+	var routes []*suggestRoute
+	r.walk("", nil, nil, nil, func(info RouteInfo) error {
+		if len(info.Methods) == 0 && info.Any == nil {
+			return nil
+		}
+
+		route := &suggestRoute{
+			pattern:  info.Pattern,
+			segments: splitSegments(info.Pattern),
+			any:      info.Any != nil,
+		}
+		for _, m := range info.Methods {
+			route.methods = append(route.methods, m.Method)
+		}
+		if route.any {
+			route.methods = append(route.methods, methodAny)
+		}
+		for _, pat := range route.segments {
+			route.length += patternSegmentLength(pat)
+			if pat == "*" || pat[0] == ':' {
+				route.open = true
+			} else {
+				route.literal += len(pat)
+			}
+		}
+
+		routes = append(routes, route)
+		return nil
+	})
+	return routes
+}
+
+// suggest finds the routes of the host closest to the path
+func (s *ServeMux) suggest(host, method, path string) []Suggestion {
+	if len(path) > maxSuggestPath {
+		return nil
+	}
+
+	root, ok := s.hostRoutes[host]
+	if !ok {
+		root = s.baseRoute
+	}
+
+	segments := splitSegments(path)
+	pathLength := 0
+	for _, seg := range segments {
+		pathLength += len(seg)
+	}
+
+	var suggestions []Suggestion
+	for _, route := range s.suggestions.get(s).routes[root] {
+		// patterns may differ by up to a third of the characters of the path or the pattern, whichever is shorter
+		limit := route.length
+		if pathLength < limit {
+			limit = pathLength
+		}
+		threshold := (limit + 1) / 3
+
+		// every literal character the path is short of costs at least one, and so does every character over
+		// unless parameters or a wildcard can take them
+		if route.literal-pathLength > threshold || (!route.open && pathLength-route.literal > threshold) {
+			continue
+		}
+
+		distance := segmentDistance(segments, route.segments)
+		if distance > threshold {
+			continue
+		}
+
+		sug := Suggestion{
+			Pattern:  route.pattern,
+			Methods:  route.methods,
+			Distance: distance,
+			Allowed:  route.any,
+		}
+		for _, m := range route.methods {
+			if m == method || (method == http.MethodHead && m == http.MethodGet) {
+				sug.Allowed = true
+			}
+		}
+		suggestions = append(suggestions, sug)
+	}
+
+	// the walk is in a stable order, so ties keep their order of precedence
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Distance != suggestions[j].Distance {
+			return suggestions[i].Distance < suggestions[j].Distance
+		}
+		return suggestions[i].Allowed && !suggestions[j].Allowed
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	// the methods are shared by every request until the routes change
+	for i := range suggestions {
+		suggestions[i].Methods = append([]string(nil), suggestions[i].Methods...)
+	}
+	return suggestions
+}
+
+// splitSegments splits a path or pattern into its non empty segments
+func splitSegments(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// segmentDistance is the edit distance between the segments of a path and a pattern.
+// Adding or removing a segment costs its length, and replacing one that differs by at most half its characters
+// costs the edit distance between the two. Path parameters match any segment, and a wildcard the rest of the path.
+func segmentDistance(path, pattern []string) int {
+	// dist[i][j] is the distance between the first i segments of the path and the first j of the pattern
+	dist := make([][]int, len(path)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(pattern)+1)
+	}
+	for i := 1; i <= len(path); i++ {
+		dist[i][0] = dist[i-1][0] + len(path[i-1])
+	}
+	for j := 1; j <= len(pattern); j++ {
+		dist[0][j] = dist[0][j-1] + patternSegmentLength(pattern[j-1])
+	}
+
+	for i := 1; i <= len(path); i++ {
+		for j := 1; j <= len(pattern); j++ {
+			seg, pat := path[i-1], pattern[j-1]
+
+			best := dist[i-1][j] + len(seg)
+			if d := dist[i][j-1] + patternSegmentLength(pat); d < best {
+				best = d
+			}
+
+			switch {
+			case pat == "*":
+				// the wildcard takes this segment and any before it that follow the previous pattern segment
+				for k := i - 1; k >= 0; k-- {
+					if dist[k][j-1] < best {
+						best = dist[k][j-1]
+					}
+				}
+			case pat[0] == ':':
+				if dist[i-1][j-1] < best {
+					best = dist[i-1][j-1]
+				}
+			default:
+				// only segments that could be a typo of the pattern are replaced, others are added and removed.
+				// Segments whose lengths differ by more than that can't be.
+				if diff := len(seg) - len(pat); diff > len(pat)/2 || -diff > len(pat)/2 {
+					break
+				}
+				if d := editDistance(seg, pat); d <= len(pat)/2 && dist[i-1][j-1]+d < best {
+					best = dist[i-1][j-1] + d
+				}
+			}
+			dist[i][j] = best
+		}
+	}
+	return dist[len(path)][len(pattern)]
+}
+
+// patternSegmentLength is the cost of a pattern segment missing from the path
+func patternSegmentLength(pat string) int {
+	switch {
+	case pat == "*":
+		return 0
+	case pat[0] == ':':
+		return 1
+	}
+	return len(pat)
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
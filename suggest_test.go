@@ -0,0 +1,204 @@
+package powermux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func suggestMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/users/:id/orders").Get(rightHandler)
+	s.Route("/users/:id/invoices").Post(rightHandler)
+	s.Route("/files/*").Any(rightHandler)
+	s.Route("/status").Get(rightHandler)
+	s.NotFound(s.SuggestNotFound(nil))
+	return s
+}
+
+// describeSuggestions summarises the suggestions
+func describeSuggestions(suggestions []Suggestion) string {
+	var desc []string
+	for _, sug := range suggestions {
+		desc = append(desc, sug.Pattern+"="+strings.Join(sug.Methods, ","))
+	}
+	return strings.Join(desc, " ")
+}
+
+func TestServeMux_Suggest(t *testing.T) {
+	s := suggestMux()
+
+	tests := []struct {
+		method      string
+		path        string
+		suggestions string
+	}{
+		{http.MethodGet, "/user/42/orders", "/users/:id/orders=GET"},
+		{http.MethodGet, "/users/42/order", "/users/:id/orders=GET"},
+		{http.MethodGet, "/users/42/invoice", "/users/:id/invoices=POST"},
+		{http.MethodGet, "/stats", "/status=GET"},
+		{http.MethodGet, "/file", "/files/*=ANY"},
+		{http.MethodGet, "/something/else/entirely", ""},
+	}
+
+	for _, test := range tests {
+		suggestions := s.suggest("", test.method, test.path)
+		if desc := describeSuggestions(suggestions); desc != test.suggestions {
+			t.Error("Wrong suggestions for", test.path, desc)
+		}
+	}
+}
+
+func TestServeMux_SuggestMethod(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/items/:id").Post(rightHandler)
+	s.Route("/item/:id").Get(rightHandler)
+
+	// equally close routes that serve the method come first
+	suggestions := s.suggest("", http.MethodGet, "/itemz/1")
+	if describeSuggestions(suggestions) != "/item/:id=GET /items/:id=POST" {
+		t.Fatal("Wrong suggestions", describeSuggestions(suggestions))
+	}
+	if !suggestions[0].Allowed || suggestions[1].Allowed || suggestions[0].Distance != 1 {
+		t.Error("Wrong ranking", suggestions)
+	}
+
+	// HEAD is served by GET handlers
+	if suggestions := s.suggest("", http.MethodHead, "/itemz/1"); !suggestions[0].Allowed {
+		t.Error("HEAD not allowed", suggestions)
+	}
+}
+
+func TestServeMux_SuggestBounds(t *testing.T) {
+	s := suggestMux()
+
+	if desc := describeSuggestions(s.suggest("", http.MethodGet, "/stats")); desc != "/status=GET" {
+		t.Error("Wrong suggestions", desc)
+	}
+
+	// the routes are indexed again once they change
+	s.Route("/stat").Get(rightHandler)
+	if desc := describeSuggestions(s.suggest("", http.MethodGet, "/stats")); desc != "/stat=GET /status=GET" {
+		t.Error("Wrong suggestions after adding a route", desc)
+	}
+
+	// suggestions can be changed without changing the index
+	s.suggest("", http.MethodGet, "/stats")[0].Methods[0] = "changed"
+	if desc := describeSuggestions(s.suggest("", http.MethodGet, "/stats")); desc != "/stat=GET /status=GET" {
+		t.Error("Index changed through a suggestion", desc)
+	}
+
+	if suggestions := s.suggest("", http.MethodGet, "/stats/"+strings.Repeat("a", maxSuggestPath)); suggestions != nil {
+		t.Error("Suggestions for a long path", describeSuggestions(suggestions))
+	}
+}
+
+func TestServeMux_SuggestHost(t *testing.T) {
+	s := suggestMux()
+	s.RouteHost("example.com", "/accounts").Get(rightHandler)
+
+	if desc := describeSuggestions(s.suggest("example.com", http.MethodGet, "/user/1/orders")); desc != "" {
+		t.Error("Suggested routes from the default tree", desc)
+	}
+	if desc := describeSuggestions(s.suggest("example.com", http.MethodGet, "/account")); desc != "/accounts=GET" {
+		t.Error("Wrong host suggestions", desc)
+	}
+}
+
+func TestSuggestNotFound_Response(t *testing.T) {
+	s := suggestMux()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/user/42/orders", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Wrong status", rec.Code)
+	}
+	if body := rec.Body.String(); body != "404 page not found\n\nDid you mean:\n\tGET /users/:id/orders\n" {
+		t.Errorf("Wrong body:\n%s", body)
+	}
+
+	// nothing close
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/something/else/entirely", nil))
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "404 page not found\n" {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+
+	// as a problem
+	s.Problems(ProblemJSON)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42/invoice", nil))
+	var problem struct {
+		Status      int          `json:"status"`
+		Suggestions []Suggestion `json:"suggestions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusNotFound || len(problem.Suggestions) != 1 ||
+		problem.Suggestions[0].Pattern != "/users/:id/invoices" || problem.Suggestions[0].Allowed {
+		t.Error("Wrong problem", problem)
+	}
+}
+
+func TestSuggestNotFound_Custom(t *testing.T) {
+	s := suggestMux()
+
+	var suggestions []Suggestion
+	s.NotFound(s.SuggestNotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suggestions = Suggestions(r)
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/user/42/orders", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Error("Custom handler not used", rec.Code)
+	}
+	if describeSuggestions(suggestions) != "/users/:id/orders=GET" {
+		t.Error("Wrong suggestions", describeSuggestions(suggestions))
+	}
+
+	// not cached with the execution
+	rec = httptest.NewRecorder()
+	s.Route("/ok").Get(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suggestions = Suggestions(r)
+	}))
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if suggestions != nil {
+		t.Error("Suggestions for a found request", suggestions)
+	}
+
+	// outside of a ServeMux
+	if Suggestions(httptest.NewRequest(http.MethodGet, "/", nil)) != nil {
+		t.Error("Suggestions without an execution")
+	}
+}
+
+func TestSegmentDistance(t *testing.T) {
+	tests := []struct {
+		path     string
+		pattern  string
+		distance int
+	}{
+		{"/users/42/orders", "/users/:id/orders", 0},
+		{"/user/42/orders", "/users/:id/orders", 1},
+		{"/users/42", "/users/:id/orders", 6},
+		{"/users/42/orders", "/users/:id", 2},
+		{"/files/a/b/c", "/files/*", 0},
+		{"/files", "/files/*", 0},
+		{"/user/42/orders", "/files/*", 5},
+		{"/", "/", 0},
+		{"/abc", "/abd", 1},
+		{"/abc", "/xyz", 6},
+	}
+
+	for _, test := range tests {
+		d := segmentDistance(splitSegments(test.path), splitSegments(test.pattern))
+		if d != test.distance {
+			t.Error("Wrong distance between", test.path, test.pattern, d)
+		}
+	}
+}
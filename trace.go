@@ -0,0 +1,297 @@
+package powermux
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AttemptKind identifies the kind of child route tried while routing a request
+type AttemptKind int
+
+const (
+	// AttemptLiteral is a search of the children with literal patterns, such as "/users"
+	AttemptLiteral AttemptKind = iota + 1
+	// AttemptParam is a try of the path parameter child, such as "/:id"
+	AttemptParam
+	// AttemptWildcard is a try of the wildcard child "/*"
+	AttemptWildcard
+)
+
+// String returns the name of the kind of attempt
+func (k AttemptKind) String() string {
+	switch k {
+	case AttemptLiteral:
+		return "literal"
+	case AttemptParam:
+		return "param"
+	case AttemptWildcard:
+		return "wildcard"
+	default:
+		return "unknown"
+	}
+}
+
+// MatchTrace is a step by step record of how a request was routed, as returned by Explain.
+type MatchTrace struct {
+	// Match is how the request was resolved, as MatchInfo would describe it
+	Match Match
+	// Method is the method of the request
+	Method string
+	// Path is the escaped path of the request
+	Path string
+	// Steps are the routes visited, from the root down
+	Steps []MatchStep
+	// Params are the path parameters captured, in order
+	Params []MatchParam
+	// Handler is the handler chosen for the request, before any middleware
+	Handler http.Handler
+	// Choice describes how the handler was chosen
+	Choice string
+	// Middleware is the middleware that runs for the request, in order
+	Middleware []Middleware
+	// NotFound is the not found handler that applies to the request, and the route it was set on
+	NotFound InheritedHandler
+	// Options is the OPTIONS handler that applies to the request, and the route it was set on.
+	// It's empty if a generated OPTIONS response would be used.
+	Options InheritedHandler
+	// Err is the reason the path was rejected, if it was malformed
+	Err *RoutingError
+
+	// the route and key of the registered handler chosen, if any
+	endpoint    *Route
+	endpointKey string
+}
+
+// MatchStep is a route visited while routing a request
+type MatchStep struct {
+	// Route is the route visited
+	Route *Route
+	// Segment is the path segment the route matched, empty for the root
+	Segment string
+	// Excluded is the inherited middleware the route stopped from running
+	Excluded []Middleware
+	// Attempts are the children tried for the next path segment, in order
+	Attempts []MatchAttempt
+	// Stop is why routing ended at this route, empty if it continued to a child
+	Stop string
+}
+
+// MatchAttempt is a child route tried for a path segment
+type MatchAttempt struct {
+	// Kind is the kind of child tried
+	Kind AttemptKind
+	// Segment is the path segment being matched
+	Segment string
+	// Route is the child tried, or nil if the route has no child of this kind that could match
+	Route *Route
+	// Matched is set if routing continued to the child
+	Matched bool
+	// Reason explains why the attempt succeeded or failed
+	Reason string
+}
+
+// MatchParam is a path parameter captured while routing a request
+type MatchParam struct {
+	Name  string
+	Value string
+}
+
+// InheritedHandler is a handler that applies to a route because it was set on that route or one above it
+type InheritedHandler struct {
+	// Handler is the handler, or nil if there isn't one
+	Handler http.Handler
+	// From is the route the handler was set on
+	From *Route
+}
+
+// Explain routes the request without serving it, and returns every step taken.
+// It's intended for tests and tooling, and is much slower than routing a request normally.
+func (s *ServeMux) Explain(req *http.Request) MatchTrace {
+	ex := newExecution()
+	ex.trace = &matchTrace{}
+	s.getAll(req, ex)
+
+	trace := MatchTrace{
+		Match:   ex.match(),
+		Method:  req.Method,
+		Path:    req.URL.EscapedPath(),
+		Steps:   ex.trace.steps,
+		Handler: registeredHandler(ex),
+		Choice:  handlerChoice(ex),
+		Err:     ex.err,
+	}
+	if ex.endpoint != nil && (ex.kind == MatchRoute || ex.kind == MatchOptions) {
+		trace.endpoint = ex.endpoint
+		trace.endpointKey = ex.endpointKey
+	}
+
+	for i := range trace.Steps {
+		step := &trace.Steps[i]
+		if h, ok := step.Route.handlers[notFound]; ok {
+			trace.NotFound = InheritedHandler{Handler: h, From: step.Route}
+		}
+		if h, ok := step.Route.handlers[http.MethodOptions]; ok {
+			trace.Options = InheritedHandler{Handler: h, From: step.Route}
+		}
+	}
+
+	// explain why routing ended where it did
+	if last := len(trace.Steps) - 1; last >= 0 {
+		step := &trace.Steps[last]
+		switch {
+		case ex.err != nil:
+			step.Stop = "path rejected: " + ex.err.Kind.String()
+		case len(step.Attempts) > 0:
+			step.Stop = "no child matches the next segment"
+		case step.Route.isWildcard:
+			step.Stop = "wildcard matches the rest of the path"
+		default:
+			step.Stop = "end of path"
+		}
+	}
+
+	for _, p := range ex.params {
+		trace.Params = append(trace.Params, MatchParam{
+			Name:  p.name,
+			Value: p.value,
+		})
+	}
+	trace.Middleware = append(trace.Middleware, ex.middleware...)
+
+	return trace
+}
+
+// matchTrace records the routes visited while routing a request, so the routing can be explained
+type matchTrace struct {
+	steps []MatchStep
+}
+
+// visit records the route, and the middleware its skips will remove from the execution
+func (t *matchTrace) visit(ex *routeExecution, route *Route, segment string, verb verbFlag) {
+	step := MatchStep{
+		Route:   route,
+		Segment: segment,
+	}
+
+	remaining := append([]Middleware(nil), ex.middleware...)
+	for _, skip := range route.skips {
+		if !skip.verb.Matches(verb) {
+			continue
+		}
+		kept := remaining[:0]
+		for _, m := range remaining {
+			if skip.Excludes(m) {
+				step.Excluded = append(step.Excluded, m)
+			} else {
+				kept = append(kept, m)
+			}
+		}
+		remaining = kept
+	}
+
+	t.steps = append(t.steps, step)
+}
+
+// search records the children of the route tried for the segment, in the same order getExecution tries them,
+// given the literal child matched if any
+func (t *matchTrace) search(route *Route, segment string, child *Route) {
+	step := &t.steps[len(t.steps)-1]
+
+	literal := MatchAttempt{
+		Kind:    AttemptLiteral,
+		Segment: segment,
+		Route:   child,
+	}
+	switch {
+	case literal.Route != nil:
+		literal.Matched = true
+		literal.Reason = "matched " + patternOf(literal.Route)
+	case len(route.children) == 0:
+		literal.Reason = "no literal children"
+	default:
+		literal.Reason = fmt.Sprintf("no literal child matches %q", segment)
+	}
+	step.Attempts = append(step.Attempts, literal)
+	if literal.Matched {
+		return
+	}
+
+	param := MatchAttempt{
+		Kind:    AttemptParam,
+		Segment: segment,
+		Route:   route.paramChild,
+	}
+	if param.Route != nil {
+		param.Matched = true
+		param.Reason = fmt.Sprintf("captured %q as %s", segment, param.Route.paramName)
+	} else {
+		param.Reason = "no path parameter child"
+	}
+	step.Attempts = append(step.Attempts, param)
+	if param.Matched {
+		return
+	}
+
+	wildcard := MatchAttempt{
+		Kind:    AttemptWildcard,
+		Segment: segment,
+		Route:   route.wildcardChild,
+	}
+	if wildcard.Route != nil {
+		wildcard.Matched = true
+		wildcard.Reason = "matched " + patternOf(wildcard.Route)
+	} else {
+		wildcard.Reason = "no wildcard child"
+	}
+	step.Attempts = append(step.Attempts, wildcard)
+}
+
+// handlerChoice describes how the handler for the execution was chosen
+func handlerChoice(ex *routeExecution) string {
+	switch ex.kind {
+	case MatchRoute:
+		switch {
+		case ex.endpointKey == methodAny:
+			return "ANY handler"
+		case ex.endpointKey != ex.method:
+			return "HEAD served by GET handler"
+		default:
+			return "explicit handler"
+		}
+	case MatchOptions:
+		if ex.endpoint == nil {
+			return "generated OPTIONS response"
+		}
+		if ex.endpoint != ex.route {
+			return "OPTIONS handler inherited from " + patternOf(ex.endpoint)
+		}
+		return "explicit handler"
+	case MatchMethodNotAllowed:
+		return "generated 405 Method Not Allowed response"
+	case MatchRedirect:
+		return "trailing slash redirect"
+	case MatchBadRequest:
+		return "bad request handler"
+	default:
+		return "NotFound handler"
+	}
+}
+
+// registeredHandler returns the handler chosen for the execution, without any handlers wrapped around it
+func registeredHandler(ex *routeExecution) http.Handler {
+	if ex.endpoint != nil && (ex.kind == MatchRoute || ex.kind == MatchOptions) {
+		return ex.endpoint.handlers[ex.endpointKey]
+	}
+	if ex.handler == http.Handler(&ex.problem) {
+		return ex.problem.gen
+	}
+	return ex.handler
+}
+
+// patternOf returns the full pattern of the route
+func patternOf(r *Route) string {
+	if r.fullPath == "" {
+		return "/"
+	}
+	return r.fullPath
+}
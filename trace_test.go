@@ -0,0 +1,123 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// describeSteps summarises the steps and attempts of a trace
+func describeSteps(trace MatchTrace) string {
+	var steps []string
+	for _, step := range trace.Steps {
+		desc := patternOf(step.Route)
+		for _, attempt := range step.Attempts {
+			result := "-"
+			if attempt.Matched {
+				result = "+"
+			}
+			desc += " " + result + attempt.Kind.String()
+		}
+		steps = append(steps, desc)
+	}
+	return strings.Join(steps, ", ")
+}
+
+func TestServeMux_Explain(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/users/:id/orders").Get(rightHandler)
+	s.Route("/users/me").Get(rightHandler)
+	s.Route("/files/*").Get(rightHandler)
+
+	tests := []struct {
+		path  string
+		kind  MatchKind
+		steps string
+		stop  string
+	}{
+		{"/users/me", MatchRoute, "/ +literal, /users +literal, /users/me", "end of path"},
+		{"/users/andrew/orders", MatchRoute,
+			"/ +literal, /users -literal +param, /users/:id +literal, /users/:id/orders", "end of path"},
+		{"/files/a/b", MatchRoute, "/ +literal, /files -literal -param +wildcard, /files/*",
+			"wildcard matches the rest of the path"},
+		{"/user/42/orders", MatchNotFound, "/ -literal -param -wildcard", "no child matches the next segment"},
+		{"/users/andrew/posts", MatchNotFound,
+			"/ +literal, /users -literal +param, /users/:id -literal -param -wildcard",
+			"no child matches the next segment"},
+		{"/users//orders", MatchBadRequest, "/ +literal, /users", "path rejected: double slash"},
+	}
+
+	for _, test := range tests {
+		trace := s.Explain(httptest.NewRequest(http.MethodGet, test.path, nil))
+		if trace.Match.Kind != test.kind {
+			t.Error("Wrong kind for", test.path, trace.Match.Kind)
+		}
+		if steps := describeSteps(trace); steps != test.steps {
+			t.Error("Wrong steps for", test.path, steps)
+		}
+		if stop := trace.Steps[len(trace.Steps)-1].Stop; stop != test.stop {
+			t.Error("Wrong stop for", test.path, stop)
+		}
+		for _, step := range trace.Steps[:len(trace.Steps)-1] {
+			if step.Stop != "" {
+				t.Error("Stop before the end for", test.path, step.Stop)
+			}
+		}
+	}
+}
+
+func TestServeMux_ExplainDetails(t *testing.T) {
+	s := NewServeMux()
+	options := dummyHandler("options")
+	s.Route("/").Options(options).Middleware(Tag(mid1, "audit"))
+	s.Route("/users").NotFound(wrongHandler).Without("audit").Middleware(mid2)
+	s.Route("/users/:id").Get(rightHandler)
+
+	trace := s.Explain(httptest.NewRequest(http.MethodHead, "/users/andrew", nil))
+
+	if trace.Match.Kind != MatchRoute || trace.Match.Pattern != "/users/:id" || trace.Handler != rightHandler {
+		t.Error("Wrong match", trace.Match, trace.Handler)
+	}
+	if trace.Choice != "HEAD served by GET handler" || trace.Method != http.MethodHead {
+		t.Error("Wrong choice", trace.Choice)
+	}
+	if len(trace.Params) != 1 || trace.Params[0] != (MatchParam{Name: "id", Value: "andrew"}) {
+		t.Error("Wrong params", trace.Params)
+	}
+	if len(trace.Middleware) != 1 || trace.Middleware[0] != mid2 {
+		t.Error("Wrong middleware", trace.Middleware)
+	}
+	if users := trace.Steps[1]; users.Segment != "users" || len(users.Excluded) != 1 {
+		t.Error("Exclusion not recorded", users.Segment, users.Excluded)
+	}
+
+	if trace.NotFound.Handler != wrongHandler || trace.NotFound.From != s.Route("/users") {
+		t.Error("Wrong not found handler", trace.NotFound)
+	}
+	if trace.Options.Handler != options || trace.Options.From != s.Route("/") {
+		t.Error("Wrong OPTIONS handler", trace.Options)
+	}
+
+	param := trace.Steps[1].Attempts[1]
+	if param.Route != s.Route("/users/:id") || param.Reason != `captured "andrew" as id` {
+		t.Error("Wrong param attempt", param.Route, param.Reason)
+	}
+	literal := trace.Steps[1].Attempts[0]
+	if literal.Route != nil || literal.Reason != "no literal children" || literal.Segment != "andrew" {
+		t.Error("Wrong literal attempt", literal.Reason)
+	}
+}
+
+func TestServeMux_ExplainStatic(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/a/b").Get(rightHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/a/b", nil)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the path has been routed, but the tree is still walked
+	if steps := describeSteps(s.Explain(req)); steps != "/ +literal, /a +literal, /a/b" {
+		t.Error("Wrong steps", steps)
+	}
+}
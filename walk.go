@@ -0,0 +1,197 @@
+package powermux
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// SkipSubtree can be returned by the function passed to Walk to skip the routes below the current one.
+var SkipSubtree = errors.New("skip this subtree")
+
+// RouteInfo describes a route visited by Walk.
+type RouteInfo struct {
+	// Route is the route itself
+	Route *Route
+	// Host is the host the route is specific to, or empty for the default routes
+	Host string
+	// Pattern is the full pattern of the route, including any parameters
+	Pattern string
+	// Params are the names of the path parameters in the pattern, in order
+	Params []string
+	// Wildcard is set if the route is a rooted subtree '/dir/*'
+	Wildcard bool
+	// Methods are the handlers registered for specific methods, sorted by method
+	Methods []MethodInfo
+	// Any is the handler registered for any method, if there is one
+	Any *MethodInfo
+	// Middleware is the middleware added to this route, in the order it runs
+	Middleware []MiddlewareInfo
+	// NotFound is the not found handler for this route, which may be inherited from a route above
+	NotFound http.Handler
+	// Options is the OPTIONS handler for this route, which may be inherited from a route above.
+	// It's nil if the generated OPTIONS response is used.
+	Options http.Handler
+}
+
+// MethodInfo describes a handler registered on a route.
+type MethodInfo struct {
+	// Method is the method the handler serves, or "ANY" for the handler for any method
+	Method string
+	// Handler is the registered handler
+	Handler http.Handler
+	// Middleware is the middleware of the groups the handler was registered through,
+	// followed by the middleware bound to the handler
+	Middleware []MiddlewareInfo
+}
+
+// MiddlewareInfo describes middleware added to a route.
+type MiddlewareInfo struct {
+	// Middleware is the middleware as it was added
+	Middleware Middleware
+	// Methods are the methods the middleware runs for, or nil if it runs for all of them
+	Methods []string
+	// Local is set if the middleware only runs for requests that end at this route
+	Local bool
+	// Conditional is set if the middleware only runs for requests that satisfy a predicate
+	Conditional bool
+}
+
+// Walk calls fn for every route, starting with the default routes and then the routes for each host in order.
+// Each route is visited before the routes below it, which are visited in order of precedence:
+// literal patterns sorted by pattern, then the path parameter, then the wildcard.
+//
+// If fn returns SkipSubtree the routes below the current one are skipped.
+// Any other error stops the walk and is returned.
+func (s *ServeMux) Walk(fn func(RouteInfo) error) error {
+	if err := s.baseRoute.walk("", nil, nil, nil, fn); err != nil {
+		return err
+	}
+
+	hosts := make([]string, 0, len(s.hostRoutes))
+	for host := range s.hostRoutes {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		if err := s.hostRoutes[host].walk(host, nil, nil, nil, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk visits this route and every route below it
+func (r *Route) walk(host string, params []string, inheritedNotFound, inheritedOptions http.Handler, fn func(RouteInfo) error) error {
+	if r.isParam {
+		// never share the backing array with a sibling's params
+		params = append(params[:len(params):len(params)], r.paramName)
+	}
+	if h, ok := r.handlers[notFound]; ok {
+		inheritedNotFound = h
+	}
+	if h, ok := r.handlers[http.MethodOptions]; ok {
+		inheritedOptions = h
+	}
+
+	info := RouteInfo{
+		Route:      r,
+		Host:       host,
+		Pattern:    r.fullPath,
+		Params:     params,
+		Wildcard:   r.isWildcard,
+		Middleware: middlewareInfo(r.middleware, false),
+		NotFound:   inheritedNotFound,
+		Options:    inheritedOptions,
+	}
+	if info.Pattern == "" {
+		info.Pattern = "/"
+	}
+	info.Middleware = append(info.Middleware, middlewareInfo(r.localMiddleware, true)...)
+
+	for _, method := range r.methods {
+		info.Methods = append(info.Methods, r.methodInfo(method))
+	}
+	if _, ok := r.handlers[methodAny]; ok {
+		anyInfo := r.methodInfo(methodAny)
+		info.Any = &anyInfo
+	}
+
+	if err := fn(info); err != nil {
+		if err == SkipSubtree {
+			return nil
+		}
+		return err
+	}
+
+	for _, child := range r.getChildren() {
+		if err := child.walk(host, params, inheritedNotFound, inheritedOptions, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// methodInfo describes the handler registered for the method
+func (r *Route) methodInfo(method string) MethodInfo {
+	return MethodInfo{
+		Method:     method,
+		Handler:    r.handlers[method],
+		Middleware: append(groupMiddlewareInfo(r.groupMiddleware[method]), middlewareInfo(r.endpointMiddleware[method], false)...),
+	}
+}
+
+// groupMiddlewareInfo describes the middleware of groups
+func groupMiddlewareInfo(groups []*groupMiddleware) []MiddlewareInfo {
+	mids := make([]*middlewareForVerb, 0, len(groups))
+	for _, g := range groups {
+		mids = append(mids, g.mid)
+	}
+	return middlewareInfo(mids, false)
+}
+
+// middlewareInfo describes the middleware
+func middlewareInfo(mids []*middlewareForVerb, local bool) []MiddlewareInfo {
+	if len(mids) == 0 {
+		return nil
+	}
+
+	infos := make([]MiddlewareInfo, 0, len(mids))
+	for _, mid := range mids {
+		infos = append(infos, MiddlewareInfo{
+			Middleware:  mid.mid,
+			Methods:     mid.verb.methods(),
+			Local:       local,
+			Conditional: mid.cond != nil,
+		})
+	}
+	return infos
+}
+
+// verbMethods are the methods for each verb flag, in flag order
+var verbMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+}
+
+// methods returns the methods the flag matches, or nil if it matches all of them
+func (f verbFlag) methods() []string {
+	if f == flagAny {
+		return nil
+	}
+
+	methods := make([]string, 0, len(verbMethods))
+	for _, method := range verbMethods {
+		if f.Matches(getVerbFlagForMethod(method)) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
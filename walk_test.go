@@ -0,0 +1,159 @@
+package powermux
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func walkPatterns(t *testing.T, s *ServeMux, fn func(RouteInfo) error) []string {
+	var patterns []string
+	err := s.Walk(func(info RouteInfo) error {
+		patterns = append(patterns, info.Host+info.Pattern)
+		if fn != nil {
+			return fn(info)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patterns
+}
+
+func TestServeMux_WalkOrder(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/b/*").Get(rightHandler)
+	s.Route("/b/:id").Get(rightHandler)
+	s.Route("/b/c").Get(rightHandler)
+	s.Route("/a").Get(rightHandler)
+	s.RouteHost("z.com", "/z").Get(rightHandler)
+	s.RouteHost("y.com", "/y").Get(rightHandler)
+
+	expected := []string{
+		"/", "/a", "/b", "/b/c", "/b/:id", "/b/*",
+		"y.com/", "y.com/y",
+		"z.com/", "z.com/z",
+	}
+
+	// the same order every time
+	for i := 0; i < 5; i++ {
+		if patterns := walkPatterns(t, s, nil); !reflect.DeepEqual(patterns, expected) {
+			t.Fatal("Wrong order", patterns)
+		}
+	}
+}
+
+func TestServeMux_WalkInfo(t *testing.T) {
+	s := NewServeMux()
+	options := dummyHandler("options")
+	s.Route("/").Options(options)
+	s.Route("/users").NotFound(wrongHandler).
+		Middleware(mid1).
+		MiddlewareFor(mid2, http.MethodGet, http.MethodPost).
+		MiddlewareIf(func(*http.Request, Match) bool { return true }, mid2).
+		LocalMiddleware(mid1)
+	s.Route("/users/:id/files/*").
+		Get(rightHandler, mid2).
+		Any(wrongHandler)
+
+	infos := make(map[string]RouteInfo)
+	walkPatterns(t, s, func(info RouteInfo) error {
+		infos[info.Pattern] = info
+		return nil
+	})
+
+	users := infos["/users"]
+	if users.Route != s.Route("/users") || users.NotFound != wrongHandler || users.Options != options {
+		t.Error("Wrong route info", users.NotFound, users.Options)
+	}
+	if len(users.Middleware) != 4 {
+		t.Fatal("Wrong middleware", users.Middleware)
+	}
+	if users.Middleware[0].Middleware != mid1 || users.Middleware[0].Methods != nil {
+		t.Error("Wrong middleware", users.Middleware[0])
+	}
+	if !reflect.DeepEqual(users.Middleware[1].Methods, []string{http.MethodGet, http.MethodPost}) {
+		t.Error("Wrong verb filter", users.Middleware[1].Methods)
+	}
+	if !users.Middleware[2].Conditional || users.Middleware[2].Local {
+		t.Error("Conditional middleware not described", users.Middleware[2])
+	}
+	if !users.Middleware[3].Local {
+		t.Error("Local middleware not described", users.Middleware[3])
+	}
+	if users.Methods != nil || users.Any != nil {
+		t.Error("Handlers for route without any", users.Methods, users.Any)
+	}
+
+	files := infos["/users/:id/files/*"]
+	if !files.Wildcard || !reflect.DeepEqual(files.Params, []string{"id"}) || files.NotFound != wrongHandler {
+		t.Error("Wrong route info", files.Wildcard, files.Params, files.NotFound)
+	}
+	if len(files.Methods) != 1 || files.Methods[0].Method != http.MethodGet || files.Methods[0].Handler != rightHandler {
+		t.Fatal("Wrong handlers", files.Methods)
+	}
+	if len(files.Methods[0].Middleware) != 1 || files.Methods[0].Middleware[0].Middleware != mid2 {
+		t.Error("Wrong bound middleware", files.Methods[0].Middleware)
+	}
+	if files.Any == nil || files.Any.Method != "ANY" || files.Any.Handler != wrongHandler {
+		t.Error("Wrong any handler", files.Any)
+	}
+
+	if infos["/"].NotFound == nil || infos["/"].Options != options {
+		t.Error("Wrong root info")
+	}
+}
+
+func TestServeMux_WalkSkip(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/a/b/c").Get(rightHandler)
+	s.Route("/d").Get(rightHandler)
+	s.RouteHost("example.com", "/e").Get(rightHandler)
+
+	patterns := walkPatterns(t, s, func(info RouteInfo) error {
+		if info.Pattern == "/a" || info.Host != "" {
+			return SkipSubtree
+		}
+		return nil
+	})
+	if !reflect.DeepEqual(patterns, []string{"/", "/a", "/d", "example.com/"}) {
+		t.Error("Wrong routes visited", patterns)
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err := s.Walk(func(info RouteInfo) error {
+		visited++
+		if info.Pattern == "/a/b" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || visited != 3 {
+		t.Error("Walk didn't stop", err, visited)
+	}
+}
+
+func TestServeMux_WalkGroup(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/users").Group(func(g *Group) {
+		g.Middleware(mid1)
+		g.Get(rightHandler, mid2)
+	})
+
+	var mids []Middleware
+	s.Walk(func(info RouteInfo) error {
+		for _, m := range info.Methods {
+			for _, mid := range m.Middleware {
+				mids = append(mids, mid.Middleware)
+			}
+		}
+		return nil
+	})
+
+	if len(mids) != 2 || mids[0] != mid1 || mids[1] != mid2 {
+		t.Error("Wrong handler middleware", mids)
+	}
+}